@@ -1,5 +1,61 @@
 package fcm
 
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidNotificationPriority occurs if the android notification priority is not one of the NotificationPriority values.
+	ErrInvalidNotificationPriority = errors.New("android notification priority is invalid")
+
+	// ErrInvalidVisibility occurs if the android notification visibility is not one of the Visibility values.
+	ErrInvalidVisibility = errors.New("android notification visibility is invalid")
+
+	// ErrInvalidVibrateTimings occurs if a vibrate timing is negative.
+	ErrInvalidVibrateTimings = errors.New("android notification vibrate timings are invalid")
+
+	// ErrInvalidNotificationCount occurs if the notification count is negative.
+	ErrInvalidNotificationCount = errors.New("android notification count is invalid")
+
+	// ErrInvalidLightSettings occurs if the light settings color is not in #rrggbb or #rrggbbaa
+	// format or a duration is not positive.
+	ErrInvalidLightSettings = errors.New("android notification light settings are invalid")
+
+	// ErrInvalidAndroidPriority occurs if the android message priority is not one of the AndroidMessagePriority values.
+	ErrInvalidAndroidPriority = errors.New("android message priority is invalid")
+
+	// ErrInvalidColor occurs if the android notification color is not in #rrggbb format.
+	ErrInvalidColor = errors.New("android notification color is invalid")
+
+	// ErrInvalidPackageName occurs if the restricted package name is not a valid android package name.
+	ErrInvalidPackageName = errors.New("android restricted package name is invalid")
+
+	// ErrInvalidChannelID occurs if the android notification channel id is blank or padded with whitespace.
+	ErrInvalidChannelID = errors.New("android notification channel id is invalid")
+)
+
+var (
+	lightSettingsColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}([0-9a-fA-F]{2})?$`)
+	colorPattern              = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+	// at least two dot separated segments, each starting with a letter
+	packageNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*(\.[a-zA-Z][a-zA-Z0-9_]*)+$`)
+
+	// seconds with up to nine fractional digits, terminated by 's'
+	durationPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,9})?s$`)
+
+	// errDurationOverflow is returned by parseDuration for well-formed
+	// durations that don't fit in a time.Duration, about 292 years.
+	errDurationOverflow = errors.New("duration overflows")
+)
+
 // AndroidNotification represents a notification to send to android devices.
 type AndroidNotification struct {
 	// The notification's title. If present, it will override
@@ -53,6 +109,232 @@ type AndroidNotification struct {
 	// in title_loc_key to use to localize the title text to the user's
 	// current localization.
 	TitleLocArgs []string `json:"title_loc_args,omitempty"`
+
+	// The notification's channel id (new in Android O). The app must create
+	// a channel with this channel ID before any notification with this
+	// channel ID is received.
+	ChannelID string `json:"channel_id,omitempty"`
+
+	// Contains the URL of an image that is going to be displayed in a
+	// notification. If present, it will override
+	// google.firebase.fcm.v1.Notification.image.
+	Image string `json:"image,omitempty"`
+
+	// Sets the "ticker" text, which is sent to accessibility services.
+	Ticker string `json:"ticker,omitempty"`
+
+	// When set to false or unset, the notification is automatically dismissed
+	// when the user clicks it in the panel. When set to true, the
+	// notification persists even when the user clicks it.
+	Sticky bool `json:"sticky,omitempty"`
+
+	// Set the time that the event in the notification occurred.
+	// Notifications in the panel are sorted by this time. Encoded as an
+	// RFC3339 timestamp.
+	EventTime time.Time `json:"-"`
+
+	// Set whether or not this notification is relevant only to the current
+	// device. Some notifications can be bridged to other devices for remote
+	// display, such as a Wear OS watch.
+	LocalOnly bool `json:"local_only,omitempty"`
+
+	// Set the relative priority for this notification.
+	NotificationPriority NotificationPriority `json:"notification_priority,omitempty"`
+
+	// If set to true, use the Android framework's default sound for the notification.
+	DefaultSound bool `json:"default_sound,omitempty"`
+
+	// If set to true, use the Android framework's default vibrate pattern
+	// for the notification.
+	DefaultVibrateTimings bool `json:"default_vibrate_timings,omitempty"`
+
+	// If set to true, use the Android framework's default LED light settings
+	// for the notification.
+	DefaultLightSettings bool `json:"default_light_settings,omitempty"`
+
+	// Set the vibration pattern to use. The first value indicates the
+	// duration to wait before turning the vibrator on. The next value
+	// indicates the duration to keep the vibrator on. Subsequent values
+	// alternate between duration to turn the vibrator off and to turn the
+	// vibrator on. Encoded as proto Duration strings, e.g. "0.5s".
+	VibrateTimings []time.Duration `json:"-"`
+
+	// Set the Notification.visibility of the notification.
+	Visibility Visibility `json:"visibility,omitempty"`
+
+	// Sets the number of items this notification represents. May be
+	// displayed as a badge count for launchers that support badging.
+	NotificationCount *int `json:"notification_count,omitempty"`
+
+	// Settings to control the notification's LED blinking rate and color if
+	// LED is available on the device.
+	LightSettings *LightSettings `json:"light_settings,omitempty"`
+}
+
+// MarshalJSON encodes EventTime as an RFC3339 timestamp and VibrateTimings as
+// proto Duration strings.
+func (n AndroidNotification) MarshalJSON() ([]byte, error) {
+	type alias AndroidNotification
+	s := struct {
+		alias
+		EventTime      string   `json:"event_time,omitempty"`
+		VibrateTimings []string `json:"vibrate_timings,omitempty"`
+	}{alias: alias(n)}
+
+	if !n.EventTime.IsZero() {
+		s.EventTime = n.EventTime.UTC().Format(time.RFC3339Nano)
+	}
+
+	for _, d := range n.VibrateTimings {
+		s.VibrateTimings = append(s.VibrateTimings, formatDuration(d))
+	}
+
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON decodes the encoding of MarshalJSON.
+func (n *AndroidNotification) UnmarshalJSON(b []byte) error {
+	type alias AndroidNotification
+	s := struct {
+		*alias
+		EventTime      string   `json:"event_time,omitempty"`
+		VibrateTimings []string `json:"vibrate_timings,omitempty"`
+	}{alias: (*alias)(n)}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	if s.EventTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, s.EventTime)
+		if err != nil {
+			return err
+		}
+		n.EventTime = t
+	}
+
+	n.VibrateTimings = nil
+	for _, v := range s.VibrateTimings {
+		d, err := parseDuration(v)
+		if err != nil {
+			return err
+		}
+		n.VibrateTimings = append(n.VibrateTimings, d)
+	}
+
+	return nil
+}
+
+// LightSettings controls the notification's LED blinking rate and color.
+type LightSettings struct {
+	// Set color of the LED in #rrggbb or #rrggbbaa format.
+	Color string `json:"-"`
+
+	// Along with LightOffDuration, define the blink rate of LED flashes.
+	LightOnDuration time.Duration `json:"-"`
+
+	// Along with LightOnDuration, define the blink rate of LED flashes.
+	LightOffDuration time.Duration `json:"-"`
+}
+
+// lightSettingsColor is the JSON representation of google.type.Color. A
+// missing alpha means the color is opaque.
+type lightSettingsColor struct {
+	Red   float64  `json:"red"`
+	Green float64  `json:"green"`
+	Blue  float64  `json:"blue"`
+	Alpha *float64 `json:"alpha,omitempty"`
+}
+
+type lightSettingsJSON struct {
+	Color            *lightSettingsColor `json:"color"`
+	LightOnDuration  string              `json:"light_on_duration"`
+	LightOffDuration string              `json:"light_off_duration"`
+}
+
+// MarshalJSON encodes the color as a google.type.Color and the durations as
+// proto Duration strings.
+func (ls LightSettings) MarshalJSON() ([]byte, error) {
+	color, err := parseLightSettingsColor(ls.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(lightSettingsJSON{
+		Color:            color,
+		LightOnDuration:  formatDuration(ls.LightOnDuration),
+		LightOffDuration: formatDuration(ls.LightOffDuration),
+	})
+}
+
+// UnmarshalJSON decodes the encoding of MarshalJSON. Missing durations are
+// left zero, for Validate to report.
+func (ls *LightSettings) UnmarshalJSON(b []byte) error {
+	var s lightSettingsJSON
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	ls.LightOnDuration, ls.LightOffDuration = 0, 0
+
+	var err error
+	if s.LightOnDuration != "" {
+		if ls.LightOnDuration, err = parseDuration(s.LightOnDuration); err != nil {
+			return err
+		}
+	}
+
+	if s.LightOffDuration != "" {
+		if ls.LightOffDuration, err = parseDuration(s.LightOffDuration); err != nil {
+			return err
+		}
+	}
+
+	ls.Color = ""
+	if c := s.Color; c != nil {
+		ls.Color = fmt.Sprintf("#%02x%02x%02x", colorByte(c.Red), colorByte(c.Green), colorByte(c.Blue))
+		if c.Alpha != nil && *c.Alpha != 1 {
+			ls.Color += fmt.Sprintf("%02x", colorByte(*c.Alpha))
+		}
+	}
+
+	return nil
+}
+
+// parseLightSettingsColor parses a #rrggbb or #rrggbbaa color.
+func parseLightSettingsColor(s string) (*lightSettingsColor, error) {
+	if !lightSettingsColorPattern.MatchString(s) {
+		return nil, ErrInvalidLightSettings
+	}
+
+	b, err := hex.DecodeString(s[1:])
+	if err != nil {
+		return nil, ErrInvalidLightSettings
+	}
+
+	color := &lightSettingsColor{
+		Red:   float64(b[0]) / 255,
+		Green: float64(b[1]) / 255,
+		Blue:  float64(b[2]) / 255,
+	}
+
+	alpha := 1.0
+	if len(b) == 4 {
+		alpha = float64(b[3]) / 255
+	}
+	color.Alpha = &alpha
+
+	return color, nil
+}
+
+func colorByte(v float64) uint8 {
+	return uint8(math.Round(v * 255))
+}
+
+// AndroidFcmOptions holds options for features provided by the FCM SDK for Android.
+type AndroidFcmOptions struct {
+	// Label associated with the message's analytics data.
+	AnalyticsLabel string `json:"analytics_label,omitempty"`
 }
 
 // AndroidConfig represents android specific options for messages sent through FCM connection server.
@@ -88,6 +370,17 @@ type AndroidConfig struct {
 	Data map[string]string `json:"data,omitempty"`
 	// Notification to send to android devices.
 	Notification *AndroidNotification `json:"notification,omitempty"`
+
+	// Options for features provided by the FCM SDK for Android.
+	FcmOptions *AndroidFcmOptions `json:"fcm_options,omitempty"`
+
+	// If set to true, messages will be allowed to be delivered to the app
+	// while the device is in direct boot mode.
+	DirectBootOk bool `json:"direct_boot_ok,omitempty"`
+
+	// If set to true, messages will be allowed to be delivered to the app
+	// while the device is in bandwidth constrained mode.
+	BandwidthConstrainedOk bool `json:"bandwidth_constrained_ok,omitempty"`
 }
 
 // AndroidMessagePriority represents the priority of a message to send to Android devices.
@@ -109,3 +402,85 @@ var (
 	// more to battery drain compared with normal priority messages.
 	AndroidHighPriority AndroidMessagePriority = "high"
 )
+
+// NotificationPriority is the relative priority of an android notification.
+type NotificationPriority string
+
+const (
+	// PriorityUnspecified means the priority is unspecified. FCM uses PriorityDefault.
+	PriorityUnspecified NotificationPriority = "PRIORITY_UNSPECIFIED"
+
+	// PriorityMin is the lowest notification priority. Notifications with
+	// this priority might not be shown to the user except under special
+	// circumstances, such as detailed notification logs.
+	PriorityMin NotificationPriority = "PRIORITY_MIN"
+
+	// PriorityLow is a lower notification priority. The UI may choose to
+	// show the notifications smaller, or at a different position in the
+	// list, compared with notifications with PriorityDefault.
+	PriorityLow NotificationPriority = "PRIORITY_LOW"
+
+	// PriorityDefault is the default notification priority.
+	PriorityDefault NotificationPriority = "PRIORITY_DEFAULT"
+
+	// PriorityHigh is a higher notification priority. Use this for more
+	// important notifications or alerts.
+	PriorityHigh NotificationPriority = "PRIORITY_HIGH"
+
+	// PriorityMax is the highest notification priority. Use this for the
+	// application's most important items that require the user's prompt
+	// attention or input.
+	PriorityMax NotificationPriority = "PRIORITY_MAX"
+)
+
+// Visibility is the visibility of an android notification on the lock screen.
+type Visibility string
+
+const (
+	// VisibilityUnspecified means the visibility is unspecified. FCM uses VisibilityPrivate.
+	VisibilityUnspecified Visibility = "VISIBILITY_UNSPECIFIED"
+
+	// VisibilityPrivate shows the notification on all lockscreens, but
+	// conceals sensitive or private information on secure lockscreens.
+	VisibilityPrivate Visibility = "PRIVATE"
+
+	// VisibilityPublic shows the notification in its entirety on all lockscreens.
+	VisibilityPublic Visibility = "PUBLIC"
+
+	// VisibilitySecret does not reveal any part of the notification on a secure lockscreen.
+	VisibilitySecret Visibility = "SECRET"
+)
+
+// formatDuration encodes d as a proto Duration string: seconds with up to
+// nine fractional digits, terminated by 's', e.g. "3.5s".
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	secs := int64(d / time.Second)
+	nanos := int64(d % time.Second)
+	if nanos == 0 {
+		return fmt.Sprintf("%s%ds", sign, secs)
+	}
+
+	return fmt.Sprintf("%s%d.%ss", sign, secs, strings.TrimRight(fmt.Sprintf("%09d", nanos), "0"))
+}
+
+// parseDuration decodes a proto Duration string. Durations out of the range
+// of time.Duration fail with errDurationOverflow.
+func parseDuration(s string) (time.Duration, error) {
+	if !durationPattern.MatchString(s) {
+		return 0, fmt.Errorf("duration '%s' is invalid", s)
+	}
+
+	// the pattern only admits valid durations, so an error is an overflow
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s'", errDurationOverflow, s)
+	}
+
+	return d, nil
+}
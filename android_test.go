@@ -0,0 +1,212 @@
+package fcm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAndroidNotificationJSON(t *testing.T) {
+	count := 3
+	n := &AndroidNotification{
+		ChannelID:            "news",
+		EventTime:            time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60)),
+		NotificationPriority: PriorityHigh,
+		VibrateTimings:       []time.Duration{500 * time.Millisecond, time.Second, 3*time.Second + time.Nanosecond},
+		Visibility:           VisibilityPublic,
+		NotificationCount:    &count,
+		LightSettings: &LightSettings{
+			Color:            "#ff000080",
+			LightOnDuration:  time.Second,
+			LightOffDuration: 1500 * time.Millisecond,
+		},
+	}
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fields["event_time"] != "2020-01-02T08:04:05Z" {
+		t.Fatalf("expected: %v got: %v", "2020-01-02T08:04:05Z", fields["event_time"])
+	}
+
+	timings := []interface{}{"0.5s", "1s", "3.000000001s"}
+	if !reflect.DeepEqual(fields["vibrate_timings"], timings) {
+		t.Fatalf("expected: %v got: %v", timings, fields["vibrate_timings"])
+	}
+
+	lights := fields["light_settings"].(map[string]interface{})
+	if lights["light_off_duration"] != "1.5s" {
+		t.Fatalf("expected: %v got: %v", "1.5s", lights["light_off_duration"])
+	}
+	if color := lights["color"].(map[string]interface{}); color["red"] != 1.0 || color["green"] != 0.0 {
+		t.Fatalf("unexpected color: %v", color)
+	}
+
+	var decoded AndroidNotification
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !decoded.EventTime.Equal(n.EventTime) {
+		t.Fatalf("expected: %v got: %v", n.EventTime, decoded.EventTime)
+	}
+	if !reflect.DeepEqual(decoded.VibrateTimings, n.VibrateTimings) {
+		t.Fatalf("expected: %v got: %v", n.VibrateTimings, decoded.VibrateTimings)
+	}
+	if !reflect.DeepEqual(decoded.LightSettings, n.LightSettings) {
+		t.Fatalf("expected: %v got: %v", n.LightSettings, decoded.LightSettings)
+	}
+
+	// missing durations are left for Validate to report
+	var ls LightSettings
+	if err := json.Unmarshal([]byte(`{"color": {"red": 1, "alpha": 1}}`), &ls); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ls.Color != "#ff0000" || ls.LightOnDuration != 0 || ls.LightOffDuration != 0 {
+		t.Fatalf("unexpected light settings: %+v", ls)
+	}
+	if err := (&Message{Topic: "test", Android: &AndroidConfig{Notification: &AndroidNotification{LightSettings: &ls}}}).Validate(); !errors.Is(err, ErrInvalidLightSettings) {
+		t.Fatalf("expected: %v got: %v", ErrInvalidLightSettings, err)
+	}
+
+	// a missing alpha means the color is opaque
+	colors := []struct {
+		json     string
+		expected string
+	}{
+		{`{"color": {"red": 1, "green": 0.5}}`, "#ff8000"},
+		{`{"color": {"blue": 1, "alpha": 0}}`, "#0000ff00"},
+		{`{"color": {"blue": 1, "alpha": 0.5}}`, "#0000ff80"},
+	}
+	for _, tt := range colors {
+		var ls LightSettings
+		if err := json.Unmarshal([]byte(tt.json), &ls); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ls.Color != tt.expected {
+			t.Fatalf("expected: %v got: %v", tt.expected, ls.Color)
+		}
+	}
+}
+
+func TestAndroidValidate(t *testing.T) {
+	count := -1
+	tests := []struct {
+		name         string
+		notification *AndroidNotification
+		err          error
+	}{
+		{"valid", &AndroidNotification{NotificationPriority: PriorityLow, Visibility: VisibilitySecret}, nil},
+		{"invalid priority", &AndroidNotification{NotificationPriority: "URGENT"}, ErrInvalidNotificationPriority},
+		{"invalid visibility", &AndroidNotification{Visibility: "HIDDEN"}, ErrInvalidVisibility},
+		{"negative vibrate timing", &AndroidNotification{VibrateTimings: []time.Duration{-time.Second}}, ErrInvalidVibrateTimings},
+		{"negative count", &AndroidNotification{NotificationCount: &count}, ErrInvalidNotificationCount},
+		{"invalid light color", &AndroidNotification{LightSettings: &LightSettings{
+			Color: "red", LightOnDuration: time.Second, LightOffDuration: time.Second,
+		}}, ErrInvalidLightSettings},
+		{"missing light duration", &AndroidNotification{LightSettings: &LightSettings{Color: "#ff0000"}}, ErrInvalidLightSettings},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &Message{
+				Topic:   "test",
+				Android: &AndroidConfig{Notification: tt.notification},
+			}
+
+			err := msg.Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestAndroidConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		android *AndroidConfig
+		paths   []string
+		err     error
+	}{
+		{"valid", &AndroidConfig{
+			Priority:              "HIGH",
+			TTL:                   "2419200s",
+			RestrictedPackageName: "com.example.app_1",
+			Notification: &AndroidNotification{
+				Color:       "#4285f4",
+				ChannelID:   "news",
+				TitleLocKey: "title",
+				BodyLocKey:  "body",
+				BodyLocArgs: []string{"a"},
+			},
+		}, nil, nil},
+		{"priority", &AndroidConfig{Priority: "urgent"}, []string{"message.android.priority"}, ErrInvalidAndroidPriority},
+		{"ttl without unit", &AndroidConfig{TTL: "60"}, []string{"message.android.ttl"}, ErrInvalidTimeToLive},
+		{"ttl in minutes", &AndroidConfig{TTL: "1m"}, []string{"message.android.ttl"}, ErrInvalidTimeToLive},
+		{"negative ttl", &AndroidConfig{TTL: "-1s"}, []string{"message.android.ttl"}, ErrInvalidTimeToLive},
+		{"ttl over 28 days", &AndroidConfig{TTL: "2419200.5s"}, []string{"message.android.ttl"}, ErrInvalidTimeToLive},
+		{"overflowing ttl", &AndroidConfig{TTL: "99999999999999999999s"}, []string{"message.android.ttl"}, ErrInvalidTimeToLive},
+		{"several issues", &AndroidConfig{
+			Priority:     "urgent",
+			TTL:          "60",
+			Notification: &AndroidNotification{Color: "red", BodyLocArgs: []string{"a"}},
+		}, []string{
+			"message.android.priority",
+			"message.android.ttl",
+			"message.android.notification.color",
+			"message.android.notification.body_loc_args",
+		}, ErrInvalidAndroidPriority},
+		{"package name with dash", &AndroidConfig{RestrictedPackageName: "com.github.go-fcm"}, []string{"message.android.restricted_package_name"}, ErrInvalidPackageName},
+		{"package name with one segment", &AndroidConfig{RestrictedPackageName: "app"}, []string{"message.android.restricted_package_name"}, ErrInvalidPackageName},
+		{"placeholder color", &AndroidConfig{Notification: &AndroidNotification{Color: "#rrggbb"}}, []string{"message.android.notification.color"}, ErrInvalidColor},
+		{"color with alpha", &AndroidConfig{Notification: &AndroidNotification{Color: "#4285f4ff"}}, []string{"message.android.notification.color"}, ErrInvalidColor},
+		{"blank channel id", &AndroidConfig{Notification: &AndroidNotification{ChannelID: " "}}, []string{"message.android.notification.channel_id"}, ErrInvalidChannelID},
+		{"title loc args without key", &AndroidConfig{Notification: &AndroidNotification{TitleLocArgs: []string{"a"}}}, []string{"message.android.notification.title_loc_args"}, ErrInvalidLocArgs},
+		{"body loc args without key", &AndroidConfig{Notification: &AndroidNotification{BodyLocArgs: []string{"a"}}}, []string{"message.android.notification.body_loc_args"}, ErrInvalidLocArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Message{Topic: "test", Android: tt.android}).Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+
+			var paths []string
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				for _, issue := range verr.Issues {
+					paths = append(paths, issue.Path)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Fatalf("expected: %v got: %v", tt.paths, paths)
+			}
+		})
+	}
+
+	t.Run("overflowing ttl message", func(t *testing.T) {
+		tests := map[string]string{
+			"99999999999999999999s":  "exceeds the maximum",
+			"-99999999999999999999s": "is negative",
+		}
+
+		for ttl, expected := range tests {
+			err := validateAndroidTTL(ttl)
+			if !errors.Is(err, ErrInvalidTimeToLive) || !strings.Contains(err.Error(), expected) {
+				t.Fatalf("expected: %v got: %v", expected, err)
+			}
+		}
+	})
+}
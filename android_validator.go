@@ -0,0 +1,129 @@
+package fcm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// maximum time to live of an android message
+const maxAndroidTTL = 28 * 24 * time.Hour
+
+// validate checks the config at path: the data, the priority, the TTL, the
+// restricted package name, the options and the notification.
+func (a *AndroidConfig) validate(v *validator, path string) {
+	validateData(v, path+".data", a.Data)
+
+	switch AndroidMessagePriority(strings.ToLower(a.Priority)) {
+	case "", AndroidNormalPriority, AndroidHighPriority:
+	default:
+		v.add(path+".priority", fmt.Errorf("%w: '%s' must be '%s' or '%s'", ErrInvalidAndroidPriority, a.Priority, AndroidNormalPriority, AndroidHighPriority))
+	}
+
+	if a.TTL != "" {
+		v.add(path+".ttl", validateAndroidTTL(a.TTL))
+	}
+
+	if a.RestrictedPackageName != "" && !packageNamePattern.MatchString(a.RestrictedPackageName) {
+		v.add(path+".restricted_package_name", fmt.Errorf("%w: '%s'", ErrInvalidPackageName, a.RestrictedPackageName))
+	}
+
+	if a.FcmOptions != nil && !validAnalyticsLabel(a.FcmOptions.AnalyticsLabel) {
+		v.add(path+".fcm_options.analytics_label", ErrInvalidAnalyticsLabel)
+	}
+
+	if a.Notification != nil {
+		a.Notification.validate(v, path+".notification")
+	}
+}
+
+// validateAndroidTTL checks that ttl is a proto Duration string between 0
+// and 28 days.
+func validateAndroidTTL(ttl string) error {
+	d, err := parseDuration(ttl)
+	if errors.Is(err, errDurationOverflow) {
+		// well-formed, but out of range: clamp it so that the checks below
+		// report it by its sign
+		d, err = math.MaxInt64, nil
+		if strings.HasPrefix(ttl, "-") {
+			d = math.MinInt64
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: '%s' must be a number of seconds terminated by 's', e.g. \"3.5s\"", ErrInvalidTimeToLive, ttl)
+	}
+
+	if d < 0 {
+		return fmt.Errorf("%w: '%s' is negative", ErrInvalidTimeToLive, ttl)
+	}
+
+	if d > maxAndroidTTL {
+		return fmt.Errorf("%w: '%s' exceeds the maximum of %s (28 days)", ErrInvalidTimeToLive, ttl, formatDuration(maxAndroidTTL))
+	}
+
+	return nil
+}
+
+// validate checks the notification at path: the color, the channel id, the
+// localization, the enums and the durations.
+func (n *AndroidNotification) validate(v *validator, path string) {
+	if n.Color != "" && !colorPattern.MatchString(n.Color) {
+		v.add(path+".color", fmt.Errorf("%w: '%s' must be in #rrggbb format", ErrInvalidColor, n.Color))
+	}
+
+	if n.ChannelID != "" && (strings.TrimSpace(n.ChannelID) != n.ChannelID || strings.TrimSpace(n.ChannelID) == "") {
+		v.add(path+".channel_id", fmt.Errorf("%w: '%s'", ErrInvalidChannelID, n.ChannelID))
+	}
+
+	if len(n.TitleLocArgs) > 0 && n.TitleLocKey == "" {
+		v.add(path+".title_loc_args", fmt.Errorf("%w: title_loc_key is not set", ErrInvalidLocArgs))
+	}
+
+	if len(n.BodyLocArgs) > 0 && n.BodyLocKey == "" {
+		v.add(path+".body_loc_args", fmt.Errorf("%w: body_loc_key is not set", ErrInvalidLocArgs))
+	}
+
+	switch n.NotificationPriority {
+	case "", PriorityUnspecified, PriorityMin, PriorityLow, PriorityDefault, PriorityHigh, PriorityMax:
+	default:
+		v.add(path+".notification_priority", ErrInvalidNotificationPriority)
+	}
+
+	switch n.Visibility {
+	case "", VisibilityUnspecified, VisibilityPrivate, VisibilityPublic, VisibilitySecret:
+	default:
+		v.add(path+".visibility", ErrInvalidVisibility)
+	}
+
+	for i, d := range n.VibrateTimings {
+		if d < 0 {
+			v.add(fmt.Sprintf("%s.vibrate_timings[%d]", path, i), ErrInvalidVibrateTimings)
+		}
+	}
+
+	if n.NotificationCount != nil && *n.NotificationCount < 0 {
+		v.add(path+".notification_count", ErrInvalidNotificationCount)
+	}
+
+	if n.LightSettings != nil {
+		n.LightSettings.validate(v, path+".light_settings")
+	}
+}
+
+// validate checks the color and the durations of the light settings at path.
+func (ls *LightSettings) validate(v *validator, path string) {
+	if _, err := parseLightSettingsColor(ls.Color); err != nil {
+		v.add(path+".color", err)
+	}
+
+	if ls.LightOnDuration <= 0 {
+		v.add(path+".light_on_duration", ErrInvalidLightSettings)
+	}
+
+	if ls.LightOffDuration <= 0 {
+		v.add(path+".light_off_duration", ErrInvalidLightSettings)
+	}
+}
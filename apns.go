@@ -2,18 +2,111 @@ package fcm
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrReservedApnsKey occurs if the custom keys of an ApnsPayload contain "aps".
+	ErrReservedApnsKey = errors.New("apns payload custom keys must not contain 'aps'")
+
+	// ErrInvalidApnsPushType occurs if the apns-push-type header is not one of the PushType values.
+	ErrInvalidApnsPushType = errors.New("apns push type is invalid")
+
+	// ErrInvalidApnsExpiration occurs if the apns-expiration header is not a UNIX epoch date in seconds.
+	ErrInvalidApnsExpiration = errors.New("apns expiration is invalid")
+
+	// ErrInvalidApnsCollapseID occurs if the apns-collapse-id header is longer than 64 bytes.
+	ErrInvalidApnsCollapseID = errors.New("apns collapse id is invalid")
+
+	// ErrUnknownApsKey occurs if the aps dictionary of a payload has a key APNs doesn't define.
+	ErrUnknownApsKey = errors.New("aps dictionary key is unknown")
 )
 
 // ApnsConfig represents Apple Push Notification Service specific options.
 type ApnsConfig struct {
-	Headers *ApnsHeaders           `json:"headers,omitempty"`
+	Headers *ApnsHeaders `json:"headers,omitempty"`
+
 	Payload map[string]interface{} `json:"payload,omitempty"`
+
+	// The APNs payload as a strongly typed ApnsPayload. If set, it is sent
+	// instead of Payload. Messages decoded from JSON only set Payload.
+	TypedPayload *ApnsPayload `json:"-"`
+}
+
+// MarshalJSON encodes TypedPayload, if set, as the payload instead of Payload.
+func (cfg ApnsConfig) MarshalJSON() ([]byte, error) {
+	type alias ApnsConfig
+	if cfg.TypedPayload == nil {
+		return json.Marshal(alias(cfg))
+	}
+
+	s := struct {
+		alias
+		Payload *ApnsPayload `json:"payload"`
+	}{alias: alias(cfg), Payload: cfg.TypedPayload}
+
+	return json.Marshal(s)
 }
 
 // ApnsPayload defines an APNS notification.
 type ApnsPayload struct {
 	Aps *ApsDictionary `json:"aps,omitempty"`
+
+	// Custom keys sent alongside the aps dictionary, at the top level of the payload.
+	Custom map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the aps dictionary and the custom keys as one object.
+func (payload ApnsPayload) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(payload.Custom)+1)
+	for k, v := range payload.Custom {
+		if k == "aps" {
+			return nil, ErrReservedApnsKey
+		}
+		m[k] = v
+	}
+
+	if payload.Aps != nil {
+		m["aps"] = payload.Aps
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes the aps dictionary and collects every other key in Custom.
+func (payload *ApnsPayload) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	payload.Aps = nil
+	if aps, ok := m["aps"]; ok {
+		payload.Aps = new(ApsDictionary)
+		if err := json.Unmarshal(aps, payload.Aps); err != nil {
+			return err
+		}
+		delete(m, "aps")
+	}
+
+	payload.Custom = nil
+	for k, raw := range m {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+
+		if payload.Custom == nil {
+			payload.Custom = make(map[string]interface{}, len(m))
+		}
+		payload.Custom[k] = v
+	}
+
+	return nil
 }
 
 // ToMap converts a ApnsPayload struct to a map[string]interface{}.
@@ -35,6 +128,9 @@ func (payload *ApnsPayload) ToMap() (map[string]interface{}, error) {
 
 // MustToMap converts a ApnsPayload struct to a map[string]interface{}.
 // It exits the program is this operation fails.
+//
+// Deprecated: use ToMap, which returns the error, or set
+// ApnsConfig.TypedPayload instead of converting the payload.
 func (payload *ApnsPayload) MustToMap() map[string]interface{} {
 	m, err := payload.ToMap()
 	if err != nil {
@@ -77,8 +173,137 @@ type ApsDictionary struct {
 	// When this key is present, the system wakes up your app in the background and
 	// delivers the notification to its app delegate.
 	ContentAvailable int `json:"content-available,omitempty"`
+
+	// Include this key with a value of 1 to let the notification service
+	// app extension of your app modify the notification before it is displayed.
+	MutableContent int `json:"mutable-content,omitempty"`
+
+	// A string that indicates the importance and delivery timing of a
+	// notification. This key was added in iOS 15.
+	InterruptionLevel InterruptionLevel `json:"interruption-level,omitempty"`
+
+	// A number between 0 and 1 that the system uses to sort the
+	// notifications from your app. The highest score gets featured in the
+	// notification summary.
+	RelevanceScore *float64 `json:"relevance-score,omitempty"`
+
+	// The identifier of the window brought forward when the user taps the notification.
+	TargetContentID string `json:"target-content-id,omitempty"`
+
+	// The criteria the system evaluates to determine if it displays the
+	// notification in the current Focus.
+	FilterCriteria string `json:"filter-criteria,omitempty"`
+
+	// The sound of a critical alert. If set, it is sent as the sound
+	// dictionary instead of Sound.
+	CriticalSound *ApnsCriticalSound `json:"-"`
 }
 
+// MarshalJSON encodes CriticalSound, if set, as the sound dictionary.
+func (aps ApsDictionary) MarshalJSON() ([]byte, error) {
+	type alias ApsDictionary
+	if aps.CriticalSound == nil {
+		return json.Marshal(alias(aps))
+	}
+
+	s := struct {
+		alias
+		Sound *ApnsCriticalSound `json:"sound"`
+	}{alias: alias(aps), Sound: aps.CriticalSound}
+
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON decodes a sound dictionary into CriticalSound and a sound name into Sound.
+func (aps *ApsDictionary) UnmarshalJSON(b []byte) error {
+	type alias ApsDictionary
+	s := struct {
+		*alias
+		Sound json.RawMessage `json:"sound,omitempty"`
+	}{alias: (*alias)(aps)}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	aps.Sound = ""
+	aps.CriticalSound = nil
+	if len(s.Sound) > 0 && s.Sound[0] == '{' {
+		aps.CriticalSound = new(ApnsCriticalSound)
+		return json.Unmarshal(s.Sound, aps.CriticalSound)
+	}
+
+	if len(s.Sound) > 0 {
+		return json.Unmarshal(s.Sound, &aps.Sound)
+	}
+
+	return nil
+}
+
+// ApnsCriticalSound is the sound dictionary of a critical alert.
+type ApnsCriticalSound struct {
+	// Whether the sound is a critical alert, which plays even if the device
+	// is muted or Do Not Disturb is on.
+	Critical bool
+
+	// The name of a sound file in your app's main bundle or in the
+	// Library/Sounds folder of your app's container directory. Use "default"
+	// to play the system sound.
+	Name string
+
+	// The volume of the critical alert's sound, between 0 (silent) and 1 (full volume).
+	Volume float64
+}
+
+type apnsCriticalSoundJSON struct {
+	Critical int     `json:"critical,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Volume   float64 `json:"volume,omitempty"`
+}
+
+// MarshalJSON encodes Critical as the integer flag APNs expects.
+func (s ApnsCriticalSound) MarshalJSON() ([]byte, error) {
+	v := apnsCriticalSoundJSON{Name: s.Name, Volume: s.Volume}
+	if s.Critical {
+		v.Critical = 1
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the encoding of MarshalJSON.
+func (s *ApnsCriticalSound) UnmarshalJSON(b []byte) error {
+	var v apnsCriticalSoundJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*s = ApnsCriticalSound{Critical: v.Critical == 1, Name: v.Name, Volume: v.Volume}
+	return nil
+}
+
+// InterruptionLevel indicates the importance and delivery timing of a notification.
+type InterruptionLevel string
+
+const (
+	// InterruptionLevelPassive adds the notification to the notification
+	// list without lighting up the screen or playing a sound.
+	InterruptionLevelPassive InterruptionLevel = "passive"
+
+	// InterruptionLevelActive presents the notification immediately, lights
+	// up the screen, and can play a sound.
+	InterruptionLevelActive InterruptionLevel = "active"
+
+	// InterruptionLevelTimeSensitive presents the notification immediately,
+	// lights up the screen, can play a sound, and breaks through system
+	// notification controls.
+	InterruptionLevelTimeSensitive InterruptionLevel = "time-sensitive"
+
+	// InterruptionLevelCritical presents the notification immediately, lights
+	// up the screen, and bypasses the mute switch to play a sound.
+	InterruptionLevelCritical InterruptionLevel = "critical"
+)
+
 // ApnsAlert represents a APNS alert
 type ApnsAlert struct {
 	// A short string describing the purpose of the notification.
@@ -97,6 +322,21 @@ type ApnsAlert struct {
 
 	// Variable string values to appear in place of the format specifiers in title-loc-key.
 	TitleLocArgs []string `json:"title-loc-args,omitempty"`
+
+	// Additional information that explains the purpose of the notification.
+	Subtitle string `json:"subtitle,omitempty"`
+
+	// The key to a subtitle string in the Localizable.strings file for the
+	// current localization.
+	SubtitleLocKey string `json:"subtitle-loc-key,omitempty"`
+
+	// Variable string values to appear in place of the format specifiers in subtitle-loc-key.
+	SubtitleLocArgs []string `json:"subtitle-loc-args,omitempty"`
+
+	// The string the notification adds to the category's summary format
+	// string when notifications are grouped.
+	SummaryArg string `json:"summary-arg,omitempty"`
+
 	// If a string is specified, the system displays an alert that includes
 	// the Close and View buttons. The string is used as a key to get a
 	// localized string in the current localization to use for the right button’s
@@ -129,7 +369,8 @@ type ApnsHeaders struct {
 	// once, repeating the attempt as needed if it is unable to deliver the notification the
 	// first time. If the value is 0, APNs treats the notification as if it expires immediately
 	// and does not store the notification or attempt to redeliver it.
-	Expiration string `json:"apns-expiration,omitempty"`
+	// Use time.Unix(0, 0) for an expiration of 0. The zero time omits the header.
+	Expiration time.Time `json:"-"`
 
 	// The priority of the notification. Specify one of the following values:
 
@@ -142,6 +383,9 @@ type ApnsHeaders struct {
 	// for the device. Notifications with this priority might be grouped and delivered
 	// in bursts. They are throttled, and in some cases are not delivered.
 
+	// 1—Prioritize the device's power considerations over all other factors for
+	// delivery, and prevent awakening the device.
+
 	// If you omit this header, the APNs server sets the priority to 10.
 	Priority string `json:"apns-priority,omitempty"`
 
@@ -162,12 +406,209 @@ type ApnsHeaders struct {
 	// Multiple notifications with the same collapse identifier are displayed to the user as
 	//  a single notification. The value of this key must not exceed 64 bytes.
 	CollapseID string `json:"apns-collapse-id,omitempty"`
+
+	// The type of the notification. Required for watchOS 6 and later, and
+	// recommended for iOS 13 and later; background notifications without it
+	// may not be delivered.
+	PushType PushType `json:"apns-push-type,omitempty"`
+
+	// A canonical UUID that is the unique ID for the notification, e.g.
+	// "123e4567-e89b-12d3-a456-4266554400a0". APNs returns it in error responses.
+	ID string `json:"apns-id,omitempty"`
+}
+
+// MarshalJSON encodes Expiration as a UNIX epoch date in seconds.
+func (h ApnsHeaders) MarshalJSON() ([]byte, error) {
+	type alias ApnsHeaders
+	s := struct {
+		alias
+		Expiration string `json:"apns-expiration,omitempty"`
+	}{alias: alias(h)}
+
+	if !h.Expiration.IsZero() {
+		s.Expiration = strconv.FormatInt(h.Expiration.Unix(), 10)
+	}
+
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON decodes the encoding of MarshalJSON.
+func (h *ApnsHeaders) UnmarshalJSON(b []byte) error {
+	type alias ApnsHeaders
+	s := struct {
+		*alias
+		Expiration string `json:"apns-expiration,omitempty"`
+	}{alias: (*alias)(h)}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	h.Expiration = time.Time{}
+	if s.Expiration != "" {
+		secs, err := strconv.ParseInt(s.Expiration, 10, 64)
+		if err != nil {
+			return ErrInvalidApnsExpiration
+		}
+		h.Expiration = time.Unix(secs, 0)
+	}
+
+	return nil
+}
+
+// PushType is the value of the apns-push-type header.
+type PushType string
+
+const (
+	// PushTypeAlert is for notifications that trigger a user interaction,
+	// such as an alert, badge or sound.
+	PushTypeAlert PushType = "alert"
+
+	// PushTypeBackground is for notifications that deliver content in the
+	// background and don't trigger any user interactions. The priority must be 5.
+	PushTypeBackground PushType = "background"
+
+	// PushTypeVoIP is for notifications that provide information about an
+	// incoming Voice-over-IP call. The topic must end in ".voip".
+	PushTypeVoIP PushType = "voip"
+
+	// PushTypeLiveActivity is for notifications that update a Live Activity.
+	// The topic must end in ".push-type.liveactivity".
+	PushTypeLiveActivity PushType = "liveactivity"
+
+	// PushTypeLocation is for notifications that request a user's location.
+	// The topic must end in ".location-query".
+	PushTypeLocation PushType = "location"
+
+	// PushTypeComplication is for notifications that contain update
+	// information for a watchOS app's complications. The topic must end in ".complication".
+	PushTypeComplication PushType = "complication"
+
+	// PushTypeFileProvider is for notifications that signal changes to a File
+	// Provider extension. The topic must end in ".pushkit.fileprovider".
+	PushTypeFileProvider PushType = "fileprovider"
+
+	// PushTypeMDM is for notifications that tell managed devices to contact
+	// the MDM server.
+	PushTypeMDM PushType = "mdm"
+)
+
+// topic suffixes that determine the push type
+var pushTypeTopicSuffixes = []struct {
+	suffix   string
+	pushType PushType
+}{
+	{".voip", PushTypeVoIP},
+	{".push-type.liveactivity", PushTypeLiveActivity},
+	{".location-query", PushTypeLocation},
+	{".complication", PushTypeComplication},
+	{".pushkit.fileprovider", PushTypeFileProvider},
+}
+
+// validPushType reports whether t is empty or one of the PushType values.
+func validPushType(t PushType) bool {
+	switch t {
+	case "", PushTypeAlert, PushTypeBackground, PushTypeVoIP, PushTypeLiveActivity,
+		PushTypeLocation, PushTypeComplication, PushTypeFileProvider, PushTypeMDM:
+		return true
+	}
+
+	return false
+}
+
+// withInferredHeaders returns a copy of the config whose push type and
+// priority, unless already set, are inferred from the topic header and the
+// aps dictionary. A payload that only sets content-available is a background
+// push with priority 5; one that shows an alert, plays a sound or sets the
+// badge is an alert push with priority 10.
+func (cfg *ApnsConfig) withInferredHeaders() (*ApnsConfig, error) {
+	headers := ApnsHeaders{}
+	if cfg.Headers != nil {
+		headers = *cfg.Headers
+	}
+
+	if headers.PushType != "" && headers.Priority != "" {
+		return cfg, nil
+	}
+
+	payload, err := cfg.typedPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	pushType := headers.PushType
+	if pushType == "" {
+		pushType = inferPushType(headers.Topic, payload.Aps)
+	}
+
+	if pushType == "" {
+		return cfg, nil
+	}
+	headers.PushType = pushType
+
+	if headers.Priority == "" {
+		switch pushType {
+		case PushTypeBackground:
+			headers.Priority = string(ApnsNormalPriority)
+		case PushTypeAlert:
+			headers.Priority = string(ApnsHighPriority)
+		}
+	}
+
+	inferred := *cfg
+	inferred.Headers = &headers
+	return &inferred, nil
+}
+
+func inferPushType(topic string, aps *ApsDictionary) PushType {
+	for _, s := range pushTypeTopicSuffixes {
+		if strings.HasSuffix(topic, s.suffix) {
+			return s.pushType
+		}
+	}
+
+	if aps == nil {
+		return ""
+	}
+
+	if aps.Alert != nil || aps.Badge != 0 || aps.Sound != "" || aps.CriticalSound != nil {
+		return PushTypeAlert
+	}
+
+	if aps.ContentAvailable == int(ApnsContentAvailable) {
+		return PushTypeBackground
+	}
+
+	return ""
+}
+
+// typedPayload returns TypedPayload, if set, or else Payload converted to an ApnsPayload.
+func (cfg *ApnsConfig) typedPayload() (*ApnsPayload, error) {
+	payload := new(ApnsPayload)
+	if cfg.TypedPayload != nil {
+		return cfg.TypedPayload, nil
+	}
+
+	b, err := json.Marshal(cfg.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
 }
 
 // ApnsMessagePriority represents the priority of the notification. Specify one of the following values:
 type ApnsMessagePriority string
 
 var (
+	// ApnsLowPriority prioritizes the device's power considerations over all
+	// other factors for delivery, and prevents awakening the device.
+	ApnsLowPriority ApnsMessagePriority = "1"
+
 	// ApnsNormalPriority sends the push message at a time that takes into account power considerations
 	// for the device. Notifications with this priority might be grouped and delivered
 	// in bursts. They are throttled, and in some cases are not delivered.
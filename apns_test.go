@@ -0,0 +1,351 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApnsPayloadJSON(t *testing.T) {
+	score := 0.5
+	payload := &ApnsPayload{
+		Aps: &ApsDictionary{
+			Alert: &ApnsAlert{
+				Subtitle:        "subtitle",
+				SubtitleLocArgs: []string{"a"},
+				SummaryArg:      "Jenna",
+			},
+			MutableContent:    1,
+			InterruptionLevel: InterruptionLevelCritical,
+			RelevanceScore:    &score,
+			TargetContentID:   "window",
+			CriticalSound:     &ApnsCriticalSound{Critical: true, Name: "alarm.aiff", Volume: 0.8},
+		},
+		Custom: map[string]interface{}{"acme": "bar"},
+	}
+
+	m, err := payload.ToMap()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m["acme"] != "bar" {
+		t.Fatalf("expected: %v got: %v", "bar", m["acme"])
+	}
+
+	aps := m["aps"].(map[string]interface{})
+	expected := map[string]interface{}{"critical": 1.0, "name": "alarm.aiff", "volume": 0.8}
+	if !reflect.DeepEqual(aps["sound"], expected) {
+		t.Fatalf("expected: %v got: %v", expected, aps["sound"])
+	}
+	if aps["interruption-level"] != "critical" || aps["mutable-content"] != 1.0 || aps["relevance-score"] != 0.5 {
+		t.Fatalf("unexpected aps: %v", aps)
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded ApnsPayload
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(&decoded, payload) {
+		t.Fatalf("expected: %+v got: %+v", payload, &decoded)
+	}
+
+	t.Run("sound name", func(t *testing.T) {
+		var decoded ApnsPayload
+		if err := json.Unmarshal([]byte(`{"aps": {"sound": "chime.aiff"}}`), &decoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if decoded.Aps.Sound != "chime.aiff" || decoded.Aps.CriticalSound != nil || decoded.Custom != nil {
+			t.Fatalf("unexpected payload: %+v", decoded.Aps)
+		}
+	})
+
+	t.Run("reserved custom key", func(t *testing.T) {
+		payload := &ApnsPayload{Custom: map[string]interface{}{"aps": "x"}}
+
+		if _, err := payload.ToMap(); err == nil {
+			t.Fatalf("expected <%v> error, but got nil", ErrReservedApnsKey)
+		}
+	})
+
+	t.Run("typed payload json", func(t *testing.T) {
+		cfg := &ApnsConfig{
+			Payload:      map[string]interface{}{"aps": map[string]interface{}{"badge": 1}},
+			TypedPayload: &ApnsPayload{Aps: &ApsDictionary{Badge: 2}, Custom: map[string]interface{}{"acme": "bar"}},
+		}
+
+		b, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := `{"payload":{"acme":"bar","aps":{"badge":2}}}`
+		if string(b) != expected {
+			t.Fatalf("expected: %v got: %v", expected, string(b))
+		}
+
+		var decoded ApnsConfig
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoded.TypedPayload != nil || decoded.Payload["acme"] != "bar" {
+			t.Fatalf("unexpected config: %+v", decoded)
+		}
+	})
+
+	t.Run("typed payload in message", func(t *testing.T) {
+		msg := &Message{
+			Topic: "test",
+			Apns: &ApnsConfig{
+				Headers:      &ApnsHeaders{Priority: string(ApnsHighPriority)},
+				TypedPayload: &ApnsPayload{Aps: &ApsDictionary{ContentAvailable: int(ApnsContentAvailable)}},
+			},
+		}
+
+		if err := msg.Validate(); !errors.Is(err, ErrInvalidApnsPriority) {
+			t.Fatalf("expected: %v got: %v", ErrInvalidApnsPriority, err)
+		}
+	})
+}
+
+func TestApnsHeadersJSON(t *testing.T) {
+	headers := &ApnsHeaders{
+		Expiration: time.Unix(14567890, 0),
+		PushType:   PushTypeBackground,
+		ID:         "123e4567-e89b-12d3-a456-4266554400a0",
+	}
+
+	b, err := json.Marshal(headers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"apns-push-type":"background","apns-id":"123e4567-e89b-12d3-a456-4266554400a0","apns-expiration":"14567890"}`
+	if string(b) != expected {
+		t.Fatalf("expected: %v got: %v", expected, string(b))
+	}
+
+	var decoded ApnsHeaders
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.Expiration.Equal(headers.Expiration) || decoded.PushType != PushTypeBackground {
+		t.Fatalf("expected: %+v got: %+v", headers, decoded)
+	}
+
+	t.Run("expire immediately", func(t *testing.T) {
+		b, err := json.Marshal(&ApnsHeaders{Expiration: time.Unix(0, 0)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != `{"apns-expiration":"0"}` {
+			t.Fatalf("expected: %v got: %v", `{"apns-expiration":"0"}`, string(b))
+		}
+	})
+
+	t.Run("invalid expiration", func(t *testing.T) {
+		var decoded ApnsHeaders
+		if err := json.Unmarshal([]byte(`{"apns-expiration":"soon"}`), &decoded); err != ErrInvalidApnsExpiration {
+			t.Fatalf("expected: %v got: %v", ErrInvalidApnsExpiration, err)
+		}
+	})
+}
+
+func TestInferApnsHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *ApnsConfig
+		pushType PushType
+		priority string
+	}{
+		{
+			name:     "background",
+			config:   &ApnsConfig{TypedPayload: &ApnsPayload{Aps: &ApsDictionary{ContentAvailable: 1}}},
+			pushType: PushTypeBackground,
+			priority: "5",
+		},
+		{
+			name:     "alert from map payload",
+			config:   &ApnsConfig{Payload: map[string]interface{}{"aps": map[string]interface{}{"badge": 3}}},
+			pushType: PushTypeAlert,
+			priority: "10",
+		},
+		{
+			name: "voip topic",
+			config: &ApnsConfig{
+				Headers:      &ApnsHeaders{Topic: "com.example.app.voip"},
+				TypedPayload: &ApnsPayload{Aps: &ApsDictionary{ContentAvailable: 1}},
+			},
+			pushType: PushTypeVoIP,
+		},
+		{
+			name: "explicit headers are kept",
+			config: &ApnsConfig{
+				Headers:      &ApnsHeaders{Priority: "10"},
+				TypedPayload: &ApnsPayload{Aps: &ApsDictionary{ContentAvailable: 1}},
+			},
+			pushType: PushTypeBackground,
+			priority: "10",
+		},
+		{
+			name:   "empty payload",
+			config: &ApnsConfig{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.config.withInferredHeaders()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var headers ApnsHeaders
+			if cfg.Headers != nil {
+				headers = *cfg.Headers
+			}
+
+			if headers.PushType != tt.pushType || headers.Priority != tt.priority {
+				t.Fatalf("expected: %v/%v got: %v/%v", tt.pushType, tt.priority, headers.PushType, headers.Priority)
+			}
+		})
+	}
+
+	t.Run("client option", func(t *testing.T) {
+		var got SendRequest
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+		c.inferApnsHeaders = true
+
+		msg := &Message{
+			Topic: "test",
+			Apns:  &ApnsConfig{TypedPayload: &ApnsPayload{Aps: &ApsDictionary{ContentAvailable: 1}}},
+		}
+		if _, err := c.SendContext(context.Background(), &SendRequest{Message: msg}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.Message.Apns.Headers == nil || got.Message.Apns.Headers.PushType != PushTypeBackground {
+			t.Fatalf("unexpected headers: %+v", got.Message.Apns.Headers)
+		}
+
+		if msg.Apns.Headers != nil {
+			t.Fatal("the message passed to SendContext must not be modified")
+		}
+	})
+}
+
+func TestApnsValidate(t *testing.T) {
+	alert := &ApnsPayload{Aps: &ApsDictionary{Alert: &ApnsAlert{Body: "hi"}}}
+	background := &ApnsPayload{Aps: &ApsDictionary{ContentAvailable: int(ApnsContentAvailable)}}
+
+	tests := []struct {
+		name         string
+		notification *Notification
+		apns         *ApnsConfig
+		paths        []string
+		err          error
+	}{
+		{"valid", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{
+				Priority:   string(ApnsLowPriority),
+				CollapseID: strings.Repeat("a", 64),
+				Expiration: time.Unix(0, 0),
+				PushType:   PushTypeBackground,
+			},
+			TypedPayload: background,
+		}, nil, nil},
+		{"valid map", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{PushType: PushTypeAlert},
+			Payload: map[string]interface{}{
+				"aps":   map[string]interface{}{"alert": map[string]interface{}{"loc-key": "GREETING", "loc-args": []string{"Jenna"}}},
+				"acme1": "bar",
+			},
+		}, nil, nil},
+		{"alert from notification", &Notification{Title: "hi"}, &ApnsConfig{Headers: &ApnsHeaders{PushType: PushTypeAlert}}, nil, nil},
+		{"collapse id too long", nil, &ApnsConfig{Headers: &ApnsHeaders{CollapseID: strings.Repeat("a", 65)}}, []string{"message.apns.headers.apns-collapse-id"}, ErrInvalidApnsCollapseID},
+		{"negative expiration", nil, &ApnsConfig{Headers: &ApnsHeaders{Expiration: time.Unix(-1, 0)}}, []string{"message.apns.headers.apns-expiration"}, ErrInvalidApnsExpiration},
+		{"priority", nil, &ApnsConfig{Headers: &ApnsHeaders{Priority: "high"}}, []string{"message.apns.headers.apns-priority"}, ErrInvalidApnsPriority},
+		{"background with priority 10", nil, &ApnsConfig{Headers: &ApnsHeaders{Priority: string(ApnsHighPriority)}, TypedPayload: background}, []string{"message.apns.headers.apns-priority"}, ErrInvalidApnsPriority},
+		{"alert without alert", nil, &ApnsConfig{Headers: &ApnsHeaders{PushType: PushTypeAlert}, TypedPayload: background}, []string{"message.apns.headers.apns-push-type"}, ErrInvalidApnsPushType},
+		{"background with alert", nil, &ApnsConfig{Headers: &ApnsHeaders{PushType: PushTypeBackground}, TypedPayload: alert}, []string{"message.apns.headers.apns-push-type"}, ErrInvalidApnsPushType},
+		{"background without content-available", nil, &ApnsConfig{Headers: &ApnsHeaders{PushType: PushTypeBackground}}, []string{"message.apns.headers.apns-push-type"}, ErrInvalidApnsPushType},
+		{"map background with alert", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{PushType: PushTypeBackground},
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"content-available": 1.0, "sound": "default"}},
+		}, []string{"message.apns.headers.apns-push-type"}, ErrInvalidApnsPushType},
+		{"loc args without key", nil, &ApnsConfig{
+			TypedPayload: &ApnsPayload{Aps: &ApsDictionary{Alert: &ApnsAlert{TitleLocArgs: []string{"Jenna"}}}},
+		}, []string{"message.apns.payload.aps.alert.title-loc-args"}, ErrInvalidLocArgs},
+		{"map loc args without key", nil, &ApnsConfig{
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"loc-args": []interface{}{"Jenna"}}}},
+		}, []string{"message.apns.payload.aps.alert.loc-args"}, ErrInvalidLocArgs},
+		{"unknown aps key", nil, &ApnsConfig{
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"badge": 1, "contentAvailable": 1}},
+		}, []string{"message.apns.payload.aps.contentAvailable"}, ErrUnknownApsKey},
+		{"unknown key of string aps map", nil, &ApnsConfig{
+			Payload: map[string]interface{}{"aps": map[string]string{"sound": "default", "contentAvailable": "1"}},
+		}, []string{"message.apns.payload.aps.contentAvailable"}, ErrUnknownApsKey},
+		{"aps dictionary in map", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{Priority: string(ApnsHighPriority)},
+			Payload: map[string]interface{}{"aps": background.Aps},
+		}, []string{"message.apns.headers.apns-priority"}, ErrInvalidApnsPriority},
+		{"map background with empty sound and zero badge", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{PushType: PushTypeBackground},
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"content-available": 1, "sound": "", "badge": 0}},
+		}, nil, nil},
+		{"map alert with zero badge", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{PushType: PushTypeAlert},
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"badge": 0}},
+		}, []string{"message.apns.headers.apns-push-type"}, ErrInvalidApnsPushType},
+		{"reserved custom key", nil, &ApnsConfig{
+			TypedPayload: &ApnsPayload{Custom: map[string]interface{}{"aps": "x"}},
+		}, []string{"message.apns.payload.aps"}, ErrReservedApnsKey},
+		{"several issues", nil, &ApnsConfig{
+			Headers: &ApnsHeaders{CollapseID: strings.Repeat("a", 65), Priority: "high"},
+			Payload: map[string]interface{}{"aps": map[string]interface{}{"badge": 1, "contentAvailable": 1}},
+		}, []string{
+			"message.apns.payload.aps.contentAvailable",
+			"message.apns.headers.apns-collapse-id",
+			"message.apns.headers.apns-priority",
+		}, ErrUnknownApsKey},
+		{"payload too large", nil, &ApnsConfig{
+			TypedPayload: &ApnsPayload{Aps: &ApsDictionary{Alert: &ApnsAlert{Body: strings.Repeat("a", maxApnsPayloadSize)}}},
+		}, []string{"message.apns.payload"}, ErrPayloadTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Message{Topic: "test", Notification: tt.notification, Apns: tt.apns}).Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+
+			var paths []string
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				for _, issue := range verr.Issues {
+					paths = append(paths, issue.Path)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Fatalf("expected: %v got: %v", tt.paths, paths)
+			}
+		})
+	}
+}
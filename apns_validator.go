@@ -0,0 +1,226 @@
+package fcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// maximum size of the apns-collapse-id header
+const maxApnsCollapseIDSize = 64
+
+// keys of the aps dictionary, including those of Live Activity updates
+var apsKeys = map[string]bool{
+	"alert":              true,
+	"badge":              true,
+	"sound":              true,
+	"thread-id":          true,
+	"category":           true,
+	"content-available":  true,
+	"mutable-content":    true,
+	"target-content-id":  true,
+	"interruption-level": true,
+	"relevance-score":    true,
+	"filter-criteria":    true,
+	"timestamp":          true,
+	"event":              true,
+	"content-state":      true,
+	"stale-date":         true,
+	"dismissal-date":     true,
+	"attributes-type":    true,
+	"attributes":         true,
+	"input-push-token":   true,
+}
+
+// localization keys of the alert dictionary and the args that go with them
+var apnsLocPairs = []struct {
+	key  string
+	args string
+}{
+	{"loc-key", "loc-args"},
+	{"title-loc-key", "title-loc-args"},
+	{"subtitle-loc-key", "subtitle-loc-args"},
+}
+
+// apsSummary describes what the validator checks of an aps dictionary, read
+// from either an *ApnsPayload or a map payload.
+type apsSummary struct {
+	// whether the notification shows an alert, plays a sound or sets the badge
+	interactive bool
+
+	// whether content-available is 1
+	contentAvailable bool
+
+	// keys set in the alert dictionary
+	alertKeys map[string]bool
+}
+
+// validate checks the config at path: the headers, the aps dictionary of the
+// payload and whether the two agree. FCM adds the title and body of
+// notification to the aps alert, so they count as an alert.
+func (cfg *ApnsConfig) validate(v *validator, path string, notification *Notification) {
+	aps := cfg.summarizePayload(v, path+".payload")
+	if notification != nil && (notification.Title != "" || notification.Body != "") {
+		aps.interactive = true
+	}
+
+	for _, pair := range apnsLocPairs {
+		if aps.alertKeys[pair.args] && !aps.alertKeys[pair.key] {
+			v.add(path+".payload.aps.alert."+pair.args, fmt.Errorf("%w: %s is not set", ErrInvalidLocArgs, pair.key))
+		}
+	}
+
+	if cfg.Headers != nil {
+		cfg.Headers.validate(v, path+".headers", aps)
+	}
+}
+
+// validate checks the headers at path against each other and against the aps
+// dictionary of the payload.
+func (h *ApnsHeaders) validate(v *validator, path string, aps apsSummary) {
+	if len(h.CollapseID) > maxApnsCollapseIDSize {
+		v.add(path+".apns-collapse-id", fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrInvalidApnsCollapseID, len(h.CollapseID), maxApnsCollapseIDSize))
+	}
+
+	if !h.Expiration.IsZero() && h.Expiration.Unix() < 0 {
+		v.add(path+".apns-expiration", fmt.Errorf("%w: %d is before the UNIX epoch", ErrInvalidApnsExpiration, h.Expiration.Unix()))
+	}
+
+	switch ApnsMessagePriority(h.Priority) {
+	case "", ApnsLowPriority, ApnsNormalPriority, ApnsHighPriority:
+	default:
+		v.add(path+".apns-priority", fmt.Errorf("%w: '%s' must be 1, 5 or 10", ErrInvalidApnsPriority, h.Priority))
+	}
+
+	if h.Priority == string(ApnsHighPriority) && aps.contentAvailable && !aps.interactive {
+		v.add(path+".apns-priority", fmt.Errorf("%w: a notification that only sets content-available must not have priority 10", ErrInvalidApnsPriority))
+	}
+
+	switch {
+	case !validPushType(h.PushType):
+		v.add(path+".apns-push-type", ErrInvalidApnsPushType)
+	case h.PushType == PushTypeAlert && !aps.interactive:
+		v.add(path+".apns-push-type", fmt.Errorf("%w: an alert push must show an alert, play a sound or set the badge", ErrInvalidApnsPushType))
+	case h.PushType == PushTypeBackground && aps.interactive:
+		v.add(path+".apns-push-type", fmt.Errorf("%w: a background push must not show an alert, play a sound or set the badge", ErrInvalidApnsPushType))
+	case h.PushType == PushTypeBackground && !aps.contentAvailable:
+		v.add(path+".apns-push-type", fmt.Errorf("%w: a background push must set content-available", ErrInvalidApnsPushType))
+	}
+}
+
+// summarizePayload summarizes the aps dictionary of the payload, reporting
+// issues of the payload at path. Map payloads are normalized with a JSON
+// round trip, whatever the types of their values, so that unknown aps keys
+// can be reported.
+func (cfg *ApnsConfig) summarizePayload(v *validator, path string) apsSummary {
+	if p := cfg.TypedPayload; p != nil {
+		if _, ok := p.Custom["aps"]; ok {
+			v.add(path+".aps", ErrReservedApnsKey)
+		}
+
+		return summarizeAps(p.Aps)
+	}
+
+	if cfg.Payload == nil {
+		return apsSummary{}
+	}
+
+	var payload map[string]interface{}
+	b, err := json.Marshal(cfg.Payload)
+	if err == nil {
+		err = json.Unmarshal(b, &payload)
+	}
+	if err != nil {
+		v.add(path, err)
+		return apsSummary{}
+	}
+
+	switch aps := payload["aps"].(type) {
+	case nil:
+		return apsSummary{}
+	case map[string]interface{}:
+		return summarizeApsMap(v, path+".aps", aps)
+	}
+
+	// not a dictionary: report why it doesn't decode as one
+	if _, err := cfg.typedPayload(); err != nil {
+		v.add(path, err)
+	}
+
+	return apsSummary{}
+}
+
+func summarizeAps(aps *ApsDictionary) apsSummary {
+	if aps == nil {
+		return apsSummary{}
+	}
+
+	s := apsSummary{
+		interactive:      aps.Alert != nil || aps.Badge != 0 || aps.Sound != "" || aps.CriticalSound != nil,
+		contentAvailable: aps.ContentAvailable == int(ApnsContentAvailable),
+		alertKeys:        make(map[string]bool),
+	}
+
+	if a := aps.Alert; a != nil {
+		s.alertKeys["loc-key"] = a.LocKey != ""
+		s.alertKeys["loc-args"] = len(a.LocArgs) > 0
+		s.alertKeys["title-loc-key"] = a.TitleLocKey != ""
+		s.alertKeys["title-loc-args"] = len(a.TitleLocArgs) > 0
+		s.alertKeys["subtitle-loc-key"] = a.SubtitleLocKey != ""
+		s.alertKeys["subtitle-loc-args"] = len(a.SubtitleLocArgs) > 0
+	}
+
+	return s
+}
+
+// summarizeApsMap summarizes an aps dictionary decoded from JSON, reporting
+// its unknown keys. Keys set to null or the zero value of their type don't
+// count, just like the omitted fields of an ApsDictionary.
+func summarizeApsMap(v *validator, path string, aps map[string]interface{}) apsSummary {
+	keys := make([]string, 0, len(aps))
+	for k := range aps {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !apsKeys[k] {
+			v.add(path+"."+k, fmt.Errorf("%w: '%s'", ErrUnknownApsKey, k))
+		}
+	}
+
+	s := apsSummary{
+		interactive:      isSet(aps["alert"]) || isSet(aps["badge"]) || isSet(aps["sound"]),
+		contentAvailable: aps["content-available"] == float64(ApnsContentAvailable),
+		alertKeys:        make(map[string]bool),
+	}
+
+	if a, ok := aps["alert"].(map[string]interface{}); ok {
+		for k, val := range a {
+			s.alertKeys[k] = isSet(val)
+		}
+	}
+
+	return s
+}
+
+// isSet reports whether v, as decoded from JSON, is neither null nor the
+// zero value of its type, e.g. "" or 0 or an empty list.
+func isSet(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case bool:
+		return x
+	case []interface{}:
+		return len(x) > 0
+	case map[string]interface{}:
+		return len(x) > 0
+	}
+
+	return true
+}
@@ -0,0 +1,230 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const defaultMaxConcurrency = 10
+
+var (
+	// ErrInvalidMulticastTemplate occurs if the multicast message template sets a target.
+	ErrInvalidMulticastTemplate = errors.New("multicast message template must not set a topic, token or condition")
+
+	// ErrNoTokens occurs if a multicast message is sent to an empty list of tokens.
+	ErrNoTokens = errors.New("at least one registration token is required")
+
+	// ErrNoMessages occurs if an empty batch of messages is sent.
+	ErrNoMessages = errors.New("at least one message is required")
+)
+
+// SendResponse is the result of sending a single message of a batch.
+type SendResponse struct {
+	// Registration token the message was sent to, if it targeted one.
+	Token string
+
+	// The identifier of the message sent, in the format of projects/*/messages/{message_id}.
+	Name string
+
+	// Error that occurred while sending the message, if any.
+	Error error
+}
+
+// Success reports whether the message was sent successfully.
+func (r *SendResponse) Success() bool {
+	return r.Error == nil
+}
+
+// MessageID returns the message id of a successfully sent message.
+func (r *SendResponse) MessageID() string {
+	return Message{Name: r.Name}.MessageID()
+}
+
+// BatchResponse holds the results of sending a batch of messages.
+type BatchResponse struct {
+	// Number of messages that were sent successfully.
+	SuccessCount int
+
+	// Number of messages that could not be sent.
+	FailureCount int
+
+	// Results of the individual messages, in the same order as the input.
+	Responses []*SendResponse
+}
+
+// SendMulticast sends the template message to each of the registration tokens.
+// The template must not set a target; it is validated once and then sent
+// to every token as its own request. Requests are sent concurrently, up to
+// the client's concurrency limit, over the client's http.Client so that
+// connections (multiplexed over HTTP/2 by the default transport) are reused.
+//
+// The returned error is only non-nil if the template or token list is
+// invalid or ctx is already done. Errors of individual tokens are reported in
+// the BatchResponse; tokens not yet sent when ctx is done fail with its error.
+func (c *Client) SendMulticast(ctx context.Context, template *Message, tokens []string) (*BatchResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := validateMulticast(template, tokens); err != nil {
+		return nil, err
+	}
+
+	responses := make([]*SendResponse, len(tokens))
+	c.fanOut(ctx, len(tokens), func(i int) {
+		msg := *template
+		msg.Token = tokens[i]
+
+		responses[i] = &SendResponse{Token: tokens[i]}
+		if err := validateToken(tokens[i]); err != nil {
+			responses[i].Error = err
+			return
+		}
+
+		responses[i].Name, responses[i].Error = c.sendMessage(ctx, &SendRequest{Message: &msg})
+	}, func(i int, err error) {
+		responses[i] = &SendResponse{Token: tokens[i], Error: err}
+	})
+
+	return newBatchResponse(responses), nil
+}
+
+// SendAll sends a batch of independent messages. Each request is validated
+// and sent concurrently, up to the client's concurrency limit. A request that
+// fails validation or cannot be sent only fails its own item.
+//
+// The returned error is only non-nil if reqs is empty or ctx is already done.
+// The responses of the BatchResponse are in the same order as reqs; messages
+// not yet sent when ctx is done fail with its error.
+func (c *Client) SendAll(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(reqs) == 0 {
+		return nil, ErrNoMessages
+	}
+
+	responses := make([]*SendResponse, len(reqs))
+	c.fanOut(ctx, len(reqs), func(i int) {
+		responses[i] = &SendResponse{}
+
+		// validate
+		if reqs[i] == nil {
+			responses[i].Error = ErrInvalidMessage
+			return
+		}
+
+		req := c.normalizeRequest(reqs[i])
+		if err := req.Message.Validate(); err != nil {
+			responses[i].Error = err
+			return
+		}
+
+		responses[i].Token = req.Message.Token
+		responses[i].Name, responses[i].Error = c.sendMessage(ctx, req)
+	}, func(i int, err error) {
+		responses[i] = &SendResponse{Error: err}
+		if reqs[i] != nil && reqs[i].Message != nil {
+			responses[i].Token = reqs[i].Message.Token
+		}
+	})
+
+	return newBatchResponse(responses), nil
+}
+
+// validateMulticast validates the template and tokens of a multicast message.
+func validateMulticast(template *Message, tokens []string) error {
+	if template == nil {
+		return ErrInvalidMessage
+	}
+
+	if template.Token != "" || template.Topic != "" || template.Condition != "" {
+		return ErrInvalidMulticastTemplate
+	}
+
+	if len(tokens) == 0 {
+		return ErrNoTokens
+	}
+
+	v := &validator{}
+	template.validatePayload(v)
+
+	return v.err()
+}
+
+// sendMessage marshals and sends an already validated request, returning the
+// name of the sent message.
+func (c *Client) sendMessage(ctx context.Context, req *SendRequest) (string, error) {
+	data, err := c.marshalRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	msg, err := c.send(ctx, data)
+	if err != nil {
+		return "", err
+	}
+
+	return msg.Name, nil
+}
+
+// fanOut calls fn for every index in [0, n) from a bounded pool of goroutines
+// and waits for all calls to return. Once ctx is done no more calls are
+// started: skip is called with the error of ctx for each remaining index.
+func (c *Client) fanOut(ctx context.Context, n int, fn func(i int), skip func(i int, err error)) {
+	workers := c.maxConcurrency
+	if workers <= 0 {
+		workers = defaultMaxConcurrency
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					skip(i, err)
+					continue
+				}
+				fn(i)
+			}
+		}()
+	}
+
+	i := 0
+	for ; i < n; i++ {
+		select {
+		case indexes <- i:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	close(indexes)
+
+	for ; i < n; i++ {
+		skip(i, ctx.Err())
+	}
+
+	wg.Wait()
+}
+
+func newBatchResponse(responses []*SendResponse) *BatchResponse {
+	br := &BatchResponse{Responses: responses}
+	for _, r := range responses {
+		if r.Success() {
+			br.SuccessCount++
+		} else {
+			br.FailureCount++
+		}
+	}
+
+	return br
+}
@@ -0,0 +1,172 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSendMulticast(t *testing.T) {
+	t.Run("per token results", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				peak := atomic.LoadInt32(&maxInFlight)
+				if n <= peak || atomic.CompareAndSwapInt32(&maxInFlight, peak, n) {
+					break
+				}
+			}
+
+			var req SendRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if req.Message.Token == "stale" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, `{"name": "projects/test/messages/%s"}`, req.Message.Token)
+		})
+		defer srv.Close()
+		c.maxConcurrency = 2
+
+		tokens := []string{"a", "stale", "b", "c", "stale"}
+		br, err := c.SendMulticast(context.Background(), &Message{Data: map[string]string{"k": "v"}}, tokens)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if br.SuccessCount != 3 || br.FailureCount != 2 {
+			t.Fatalf("unexpected counts: %d successes, %d failures", br.SuccessCount, br.FailureCount)
+		}
+		for i, r := range br.Responses {
+			if r.Token != tokens[i] {
+				t.Fatalf("expected: %v got: %v", tokens[i], r.Token)
+			}
+			if r.Token == "stale" {
+				if !IsUnregistered(r.Error) {
+					t.Fatalf("expected %v, got %v", ErrorCodeUnregistered, r.Error)
+				}
+			} else if r.MessageID() != r.Token {
+				t.Fatalf("expected: %v got: %v", r.Token, r.MessageID())
+			}
+		}
+		if maxInFlight > 2 {
+			t.Fatalf("expected at most 2 concurrent requests, got %d", maxInFlight)
+		}
+	})
+
+	t.Run("template with target", func(t *testing.T) {
+		c := &Client{}
+		_, err := c.SendMulticast(context.Background(), &Message{Topic: "cats"}, []string{"a"})
+		if err != ErrInvalidMulticastTemplate {
+			t.Fatalf("expected: %v got: %v", ErrInvalidMulticastTemplate, err)
+		}
+	})
+
+	t.Run("no tokens", func(t *testing.T) {
+		c := &Client{}
+		_, err := c.SendMulticast(context.Background(), &Message{}, nil)
+		if err != ErrNoTokens {
+			t.Fatalf("expected: %v got: %v", ErrNoTokens, err)
+		}
+	})
+
+	t.Run("cancelled mid batch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var calls int32
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			cancel()
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+		c.maxConcurrency = 1
+
+		tokens := []string{"a", "b", "c", "d"}
+		br, err := c.SendMulticast(ctx, &Message{}, tokens)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected: %v got: %v", 1, calls)
+		}
+		for i, r := range br.Responses[1:] {
+			if r.Token != tokens[i+1] || !errors.Is(r.Error, context.Canceled) {
+				t.Fatalf("unexpected response: %+v", r)
+			}
+		}
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+
+		apnsToken := strings.Repeat("ab", 32)
+		br, err := c.SendMulticast(context.Background(), &Message{}, []string{"a", apnsToken})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if br.SuccessCount != 1 || !errors.Is(br.Responses[1].Error, ErrInvalidToken) {
+			t.Fatalf("expected: %v got: %v", ErrInvalidToken, br.Responses[1].Error)
+		}
+	})
+}
+
+func TestSendAll(t *testing.T) {
+	t.Run("results in input order", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			var req SendRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			fmt.Fprintf(w, `{"name": "projects/test/messages/%s"}`, req.Message.Data["id"])
+		})
+		defer srv.Close()
+
+		reqs := []*SendRequest{
+			{Message: &Message{Token: "a", Data: map[string]string{"id": "1"}}},
+			{Message: &Message{Data: map[string]string{"id": "2"}}},
+			nil,
+			{Message: &Message{Topic: "cats", Data: map[string]string{"id": "4"}}},
+		}
+
+		br, err := c.SendAll(context.Background(), reqs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if br.SuccessCount != 2 || br.FailureCount != 2 {
+			t.Fatalf("unexpected counts: %d successes, %d failures", br.SuccessCount, br.FailureCount)
+		}
+		if br.Responses[0].MessageID() != "1" || br.Responses[0].Token != "a" {
+			t.Fatalf("unexpected response: %+v", br.Responses[0])
+		}
+		if !errors.Is(br.Responses[1].Error, ErrInvalidTarget) {
+			t.Fatalf("expected: %v got: %v", ErrInvalidTarget, br.Responses[1].Error)
+		}
+		if br.Responses[2].Error != ErrInvalidMessage {
+			t.Fatalf("expected: %v got: %v", ErrInvalidMessage, br.Responses[2].Error)
+		}
+		if br.Responses[3].MessageID() != "4" {
+			t.Fatalf("expected: %v got: %v", "4", br.Responses[3].MessageID())
+		}
+	})
+
+	t.Run("no messages", func(t *testing.T) {
+		c := &Client{}
+		_, err := c.SendAll(context.Background(), nil)
+		if err != ErrNoMessages {
+			t.Fatalf("expected: %v got: %v", ErrNoMessages, err)
+		}
+	})
+}
@@ -2,7 +2,9 @@ package fcm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
@@ -10,31 +12,55 @@ import (
 
 const (
 	endpointFormat = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	iidEndpoint    = "https://iid.googleapis.com"
 )
 
 // Client abstracts the interaction between the application server and the
-// FCM server via HTTP protocol. The developer must obtain a service account
-// private key in JSON and the Firebase project id from the Firebase console and pass it to the `Client`
+// FCM server via HTTP protocol. The developer must obtain credentials, e.g. a
+// service account private key in JSON from the Firebase console, and pass them to the `Client`
 // so that it can perform authorized requests on the application server's behalf.
 type Client struct {
 	// https://firebase.google.com/docs/reference/fcm/rest/v1/projects.messages/send
 	projectID     string
 	endpoint      string
+	iidEndpoint   string
 	client        *http.Client
+	credentials   credentialsSource
+	stsEndpoint   string
 	tokenProvider *tokenProvider
+	retryPolicy   *RetryPolicy
+
+	// maximum number of requests sent concurrently by batch operations
+	maxConcurrency int
+
+	// infer missing apns-push-type and apns-priority headers from the payload
+	inferApnsHeaders bool
+
+	// strip the "/topics/" prefix of message topics instead of rejecting it
+	normalizeTopics bool
 }
 
 // NewClient creates new Firebase Cloud Messaging Client based on a json service account file credentials file.
+// If projectID is empty, it is detected from the credentials.
 func NewClient(projectID string, credentialsLocation string, opts ...Option) (*Client, error) {
-	tp, err := newTokenProvider(credentialsLocation)
-	if err != nil {
-		return nil, err
+	options := []Option{WithCredentialsFile(credentialsLocation)}
+	if projectID != "" {
+		options = append(options, WithProjectID(projectID))
 	}
 
+	return NewClientWithOptions(append(options, opts...)...)
+}
+
+// NewClientWithOptions creates new Firebase Cloud Messaging Client configured by opts.
+// Without a credentials option, Application Default Credentials are used. Without
+// WithProjectID, the project id is detected from the credentials or the
+// GOOGLE_CLOUD_PROJECT environment variable.
+func NewClientWithOptions(opts ...Option) (*Client, error) {
 	c := &Client{
-		endpoint:      fmt.Sprintf(endpointFormat, projectID),
-		client:        http.DefaultClient,
-		tokenProvider: tp,
+		iidEndpoint:    iidEndpoint,
+		client:         http.DefaultClient,
+		credentials:    defaultCredentials,
+		maxConcurrency: defaultMaxConcurrency,
 	}
 
 	for _, o := range opts {
@@ -43,85 +69,180 @@ func NewClient(projectID string, credentialsLocation string, opts ...Option) (*C
 		}
 	}
 
+	creds, err := c.credentials(context.Background(), c)
+	if err != nil {
+		return nil, err
+	}
+	c.tokenProvider = newTokenProvider(creds.tokenSource)
+
+	if c.projectID == "" {
+		c.projectID = creds.projectID
+	}
+
+	if c.projectID == "" {
+		c.projectID = projectIDFromEnv()
+	}
+
+	if c.endpoint == "" {
+		if c.projectID == "" {
+			return nil, errors.New("fcm: project id could not be detected, use WithProjectID")
+		}
+		c.endpoint = fmt.Sprintf(endpointFormat, c.projectID)
+	}
+
 	return c, nil
 }
 
 // Send sends a message to the FCM server.
 func (c *Client) Send(req *SendRequest) (*Message, error) {
+	return c.SendContext(context.Background(), req)
+}
+
+// SendContext sends a message to the FCM server. The context is used both
+// for fetching the bearer token and for the HTTP request, so cancelling it
+// aborts the send and returns ctx.Err().
+func (c *Client) SendContext(ctx context.Context, req *SendRequest) (*Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// validate
+	if req == nil {
+		return nil, ErrInvalidMessage
+	}
+
+	req = c.normalizeRequest(req)
 	if err := req.Message.Validate(); err != nil {
 		return nil, err
 	}
 
-	// marshal message
-	data, err := json.Marshal(req)
+	data, err := c.marshalRequest(req)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.send(data)
+	return c.send(ctx, data)
 }
 
-// send sends a request.
-func (c *Client) send(data []byte) (*Message, error) {
-	// create request
-	req, err := http.NewRequest("POST", c.endpoint, bytes.NewBuffer(data))
+// normalizeRequest strips the "/topics/" prefix of the topic of the
+// message if the client is configured to.
+func (c *Client) normalizeRequest(req *SendRequest) *SendRequest {
+	if !c.normalizeTopics || req.Message == nil || NormalizeTopic(req.Message.Topic) == req.Message.Topic {
+		return req
+	}
+
+	msg := *req.Message
+	msg.Topic = NormalizeTopic(msg.Topic)
+	return &SendRequest{ValidateOnly: req.ValidateOnly, Message: &msg}
+}
+
+// marshalRequest marshals a validated request, inferring its APNs headers
+// if the client is configured to.
+func (c *Client) marshalRequest(req *SendRequest) ([]byte, error) {
+	if c.inferApnsHeaders && req.Message.Apns != nil {
+		apns, err := req.Message.Apns.withInferredHeaders()
+		if err != nil {
+			return nil, err
+		}
+
+		msg := *req.Message
+		msg.Apns = apns
+		req = &SendRequest{ValidateOnly: req.ValidateOnly, Message: &msg}
+	}
+
+	return json.Marshal(req)
+}
+
+// send sends a message request.
+func (c *Client) send(ctx context.Context, data []byte) (*Message, error) {
+	response := new(Message)
+	err := c.do(ctx, func() (*http.Request, error) {
+		return newJSONRequest(http.MethodPost, c.endpoint, data)
+	}, response)
 	if err != nil {
 		return nil, err
 	}
 
+	return response, nil
+}
+
+// do executes the request built by newRequest and decodes the JSON response
+// into v, retrying it according to the client's retry policy. newRequest is
+// called once per attempt.
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error), v interface{}) error {
+	if c.retryPolicy == nil {
+		return c.doOnce(ctx, newRequest, v)
+	}
+
+	return c.retryPolicy.do(ctx, func() error {
+		return c.doOnce(ctx, newRequest, v)
+	})
+}
+
+// doOnce executes a single authorized request.
+func (c *Client) doOnce(ctx context.Context, newRequest func() (*http.Request, error), v interface{}) error {
+	// create request
+	req, err := newRequest()
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
 	// get bearer token
-	token, err := c.tokenProvider.token()
+	token, err := c.tokenProvider.token(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// add headers
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Add("Content-Type", "application/json")
 
 	// execute request
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		requestBytes, _ := httputil.DumpRequest(req, true)
+		requestDump := dumpRequest(req)
 		responseBytes, _ := httputil.DumpResponse(resp, true)
 
-		if resp.StatusCode >= http.StatusInternalServerError {
-			return nil, HttpError{
-				RequestDump:  string(requestBytes),
-				ResponseDump: string(responseBytes),
-				Err:          fmt.Errorf(fmt.Sprintf("%d error: %s", resp.StatusCode, resp.Status)),
-			}
-		}
-		return nil, HttpError{
-			RequestDump:  string(requestBytes),
-			ResponseDump: string(responseBytes),
-			Err:          fmt.Errorf("%d error: %s", resp.StatusCode, resp.Status),
-		}
+		e := newError(resp)
+		e.RequestDump = requestDump
+		e.ResponseDump = string(responseBytes)
+		return e
 	}
 
 	// build return
-	response := new(Message)
-	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
-		return nil, err
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// dumpRequest dumps a sent request, without its Authorization header.
+func dumpRequest(req *http.Request) string {
+	dump := req.Clone(req.Context())
+	dump.Header.Del("Authorization")
+
+	dump.Body = nil
+	if req.GetBody != nil {
+		dump.Body, _ = req.GetBody()
 	}
 
-	return response, nil
+	b, _ := httputil.DumpRequestOut(dump, dump.Body != nil)
+	return string(b)
 }
 
-// HttpError contains the dump of the request and response for debugging purposes.
-type HttpError struct {
-	RequestDump  string
-	ResponseDump string
-	Err          error
-}
+// newJSONRequest creates a request with a JSON body.
+func newJSONRequest(method, url string, data []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
 
-func (fcmError HttpError) Error() string {
-	return fcmError.Err.Error()
+	req.Header.Add("Content-Type", "application/json")
+	return req, nil
 }
@@ -0,0 +1,105 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func newTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	c := &Client{
+		endpoint:    srv.URL,
+		iidEndpoint: srv.URL,
+		client:      srv.Client(),
+		tokenProvider: newTokenProvider(staticTokenSourceFunc(
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"}),
+		)),
+	}
+
+	return c, srv
+}
+
+func TestSendContext(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+				t.Errorf("expected: %v got: %v", "Bearer test-token", got)
+			}
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+
+		msg, err := c.SendContext(context.Background(), &SendRequest{Message: &Message{Topic: "cats"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if msg.MessageID() != "1" {
+			t.Fatalf("expected: %v got: %v", "1", msg.MessageID())
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("request should not be sent")
+		})
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.SendContext(ctx, &SendRequest{Message: &Message{Topic: "cats"}})
+		if err != context.Canceled {
+			t.Fatalf("expected: %v got: %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		done := make(chan struct{})
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		})
+		defer srv.Close()
+		defer close(done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := c.SendContext(ctx, &SendRequest{Message: &Message{Topic: "cats"}})
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected: %v got: %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestTopicNormalization(t *testing.T) {
+	var got SendRequest
+	c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+	})
+	defer srv.Close()
+
+	req := &SendRequest{Message: &Message{Topic: "/topics/news"}}
+	if _, err := c.SendContext(context.Background(), req); !errors.Is(err, ErrInvalidTopic) {
+		t.Fatalf("expected: %v got: %v", ErrInvalidTopic, err)
+	}
+
+	c.normalizeTopics = true
+	if _, err := c.SendContext(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Message.Topic != "news" {
+		t.Fatalf("expected: %v got: %v", "news", got.Message.Topic)
+	}
+	if req.Message.Topic != "/topics/news" {
+		t.Fatal("the message passed to SendContext must not be modified")
+	}
+}
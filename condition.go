@@ -0,0 +1,530 @@
+package fcm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maximum number of topics in a condition
+const maxConditionTopics = 5
+
+// Condition is a node of the syntax tree of a condition expression, e.g.
+// "'stock' in topics && ('GOOG' in topics || 'AAPL' in topics)".
+type Condition interface {
+	// String renders the condition in the syntax of Message.Condition.
+	String() string
+
+	// Topics returns the topics of the condition in order of appearance.
+	Topics() []string
+
+	// And returns the condition "c && other".
+	And(other Condition) Condition
+
+	// Or returns the condition "c || other".
+	Or(other Condition) Condition
+
+	// Not returns the condition "!c".
+	Not() Condition
+
+	// Validate checks the topic names and that the condition has at most
+	// five topics. Errors are of type *ConditionError, with the position in
+	// the rendered condition.
+	Validate() error
+
+	// precedence of the node, used to parenthesize operands
+	precedence() int
+
+	// evaluate reports whether a device subscribed to topics matches the condition
+	evaluate(topics map[string]bool) bool
+}
+
+// Topic returns the condition that matches devices subscribed to topic. It
+// is the starting point to build conditions, e.g.
+//
+//	fcm.Topic("a").And(fcm.Topic("b").Or(fcm.Topic("c"))).String()
+//
+// renders "'a' in topics && ('b' in topics || 'c' in topics)". Validate the
+// condition before rendering it; String does not check the topic names or
+// the number of topics.
+func Topic(topic string) *TopicCondition {
+	return &TopicCondition{Name: topic}
+}
+
+// Evaluate reports whether a device subscribed to subscribedTopics would
+// receive a message sent to the condition expression cond.
+func Evaluate(cond string, subscribedTopics []string) (bool, error) {
+	c, err := ParseCondition(cond)
+	if err != nil {
+		return false, err
+	}
+
+	topics := make(map[string]bool, len(subscribedTopics))
+	for _, topic := range subscribedTopics {
+		topics[strings.TrimPrefix(topic, topicPrefix)] = true
+	}
+
+	return c.evaluate(topics), nil
+}
+
+// ConditionOp is a binary operator of a condition.
+type ConditionOp string
+
+const (
+	// ConditionAnd is the "&&" operator.
+	ConditionAnd ConditionOp = "&&"
+
+	// ConditionOr is the "||" operator.
+	ConditionOr ConditionOp = "||"
+)
+
+// TopicCondition matches devices subscribed to a topic: "'Topic' in topics".
+type TopicCondition struct {
+	// Name of the topic, without quotes.
+	Name string
+
+	// Byte offset of the topic in the parsed expression.
+	Pos int
+}
+
+// String implements Condition.
+func (c *TopicCondition) String() string {
+	return fmt.Sprintf("'%s' in topics", c.Name)
+}
+
+// Topics implements Condition.
+func (c *TopicCondition) Topics() []string {
+	return []string{c.Name}
+}
+
+func (c *TopicCondition) precedence() int {
+	return 3
+}
+
+// And implements Condition.
+func (c *TopicCondition) And(other Condition) Condition {
+	return &BinaryCondition{Op: ConditionAnd, X: c, Y: other}
+}
+
+// Or implements Condition.
+func (c *TopicCondition) Or(other Condition) Condition {
+	return &BinaryCondition{Op: ConditionOr, X: c, Y: other}
+}
+
+// Not implements Condition.
+func (c *TopicCondition) Not() Condition {
+	return &NotCondition{X: c}
+}
+
+// Validate implements Condition.
+func (c *TopicCondition) Validate() error {
+	return validateCondition(c)
+}
+
+func (c *TopicCondition) evaluate(topics map[string]bool) bool {
+	return topics[c.Name]
+}
+
+// NotCondition negates a condition: "!X".
+type NotCondition struct {
+	X Condition
+
+	// Byte offset of the "!" in the parsed expression.
+	Pos int
+}
+
+// String implements Condition. The operand is always parenthesized, as in
+// the FCM documentation.
+func (c *NotCondition) String() string {
+	return "!(" + c.X.String() + ")"
+}
+
+// Topics implements Condition.
+func (c *NotCondition) Topics() []string {
+	return c.X.Topics()
+}
+
+func (c *NotCondition) precedence() int {
+	return 3
+}
+
+// And implements Condition.
+func (c *NotCondition) And(other Condition) Condition {
+	return &BinaryCondition{Op: ConditionAnd, X: c, Y: other}
+}
+
+// Or implements Condition.
+func (c *NotCondition) Or(other Condition) Condition {
+	return &BinaryCondition{Op: ConditionOr, X: c, Y: other}
+}
+
+// Not implements Condition.
+func (c *NotCondition) Not() Condition {
+	return &NotCondition{X: c}
+}
+
+// Validate implements Condition.
+func (c *NotCondition) Validate() error {
+	return validateCondition(c)
+}
+
+func (c *NotCondition) evaluate(topics map[string]bool) bool {
+	return !c.X.evaluate(topics)
+}
+
+// BinaryCondition combines two conditions with "&&" or "||".
+type BinaryCondition struct {
+	Op   ConditionOp
+	X, Y Condition
+
+	// Byte offset of the operator in the parsed expression.
+	Pos int
+}
+
+// String implements Condition.
+func (c *BinaryCondition) String() string {
+	return parenthesize(c.X, c.precedence()) + " " + string(c.Op) + " " + parenthesize(c.Y, c.precedence())
+}
+
+// Topics implements Condition.
+func (c *BinaryCondition) Topics() []string {
+	return append(c.X.Topics(), c.Y.Topics()...)
+}
+
+func (c *BinaryCondition) precedence() int {
+	if c.Op == ConditionAnd {
+		return 2
+	}
+
+	return 1
+}
+
+// And implements Condition.
+func (c *BinaryCondition) And(other Condition) Condition {
+	return &BinaryCondition{Op: ConditionAnd, X: c, Y: other}
+}
+
+// Or implements Condition.
+func (c *BinaryCondition) Or(other Condition) Condition {
+	return &BinaryCondition{Op: ConditionOr, X: c, Y: other}
+}
+
+// Not implements Condition.
+func (c *BinaryCondition) Not() Condition {
+	return &NotCondition{X: c}
+}
+
+// Validate implements Condition.
+func (c *BinaryCondition) Validate() error {
+	return validateCondition(c)
+}
+
+func (c *BinaryCondition) evaluate(topics map[string]bool) bool {
+	if c.Op == ConditionAnd {
+		return c.X.evaluate(topics) && c.Y.evaluate(topics)
+	}
+
+	return c.X.evaluate(topics) || c.Y.evaluate(topics)
+}
+
+// validateCondition checks the topic names of c and that it has at most
+// maxConditionTopics topics.
+func validateCondition(c Condition) error {
+	rendered := c.String()
+	offset := 0
+
+	for i, topic := range c.Topics() {
+		// topics are rendered in order, so each is found after the previous one
+		pos := offset + strings.Index(rendered[offset:], "'"+topic+"'")
+		offset = pos + len(topic) + 2
+
+		if i == maxConditionTopics {
+			return &ConditionError{Pos: pos, Msg: fmt.Sprintf("condition has more than %d topics", maxConditionTopics)}
+		}
+
+		if err := validateTopicName(topic); err != nil {
+			return &ConditionError{Pos: pos, Msg: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// parenthesize renders c, in parentheses if it binds less tightly than an
+// operator of precedence p.
+func parenthesize(c Condition, p int) string {
+	if c.precedence() < p {
+		return "(" + c.String() + ")"
+	}
+
+	return c.String()
+}
+
+// ConditionError describes a syntax error or a limit violation of a
+// condition. It matches ErrInvalidTarget with errors.Is.
+type ConditionError struct {
+	// Byte offset of the error in the expression.
+	Pos int
+
+	// Description of the error.
+	Msg string
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("condition is invalid at position %d: %s", e.Pos, e.Msg)
+}
+
+// Unwrap returns ErrInvalidTarget.
+func (e *ConditionError) Unwrap() error {
+	return ErrInvalidTarget
+}
+
+// ParseCondition parses a condition expression as used by Message.Condition.
+// The grammar is:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" expr ")" | topic "in" "topics"
+//	topic   = "'" name "'" | '"' name '"'
+//
+// where a topic name matches [a-zA-Z0-9-_.~%]+. A condition may contain at
+// most five topics. Errors are of type *ConditionError.
+func ParseCondition(expr string) (Condition, error) {
+	p := &conditionParser{lexer: conditionLexer{src: expr}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	return c, nil
+}
+
+type conditionParser struct {
+	lexer  conditionLexer
+	tok    conditionToken
+	topics int
+}
+
+func (p *conditionParser) next() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.tok = tok
+	return nil
+}
+
+func (p *conditionParser) errorf(format string, args ...interface{}) error {
+	return &ConditionError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *conditionParser) parseOr() (Condition, error) {
+	return p.parseBinary(ConditionOr, tokenOr, p.parseAnd)
+}
+
+func (p *conditionParser) parseAnd() (Condition, error) {
+	return p.parseBinary(ConditionAnd, tokenAnd, p.parseUnary)
+}
+
+// parseBinary parses a left associative chain of operands joined by op.
+func (p *conditionParser) parseBinary(op ConditionOp, kind tokenKind, operand func() (Condition, error)) (Condition, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == kind {
+		pos := p.tok.pos
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		x = &BinaryCondition{Op: op, X: x, Y: y, Pos: pos}
+	}
+
+	return x, nil
+}
+
+func (p *conditionParser) parseUnary() (Condition, error) {
+	if p.tok.kind != tokenNot {
+		return p.parsePrimary()
+	}
+
+	pos := p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotCondition{X: x, Pos: pos}, nil
+}
+
+func (p *conditionParser) parsePrimary() (Condition, error) {
+	switch p.tok.kind {
+	case tokenLParen:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.tok.kind != tokenRParen {
+			return nil, p.errorf("expected ')', found %s", p.tok)
+		}
+
+		return x, p.next()
+	case tokenTopic:
+		p.topics++
+		if p.topics > maxConditionTopics {
+			return nil, p.errorf("condition has more than %d topics", maxConditionTopics)
+		}
+
+		topic := &TopicCondition{Name: p.tok.value, Pos: p.tok.pos}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		for _, keyword := range []string{"in", "topics"} {
+			if p.tok.kind != tokenIdent || p.tok.value != keyword {
+				return nil, p.errorf("expected '%s', found %s", keyword, p.tok)
+			}
+
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		return topic, nil
+	}
+
+	return nil, p.errorf("expected topic, '(' or '!', found %s", p.tok)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenTopic
+	tokenIdent
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type conditionToken struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t conditionToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of condition"
+	case tokenTopic:
+		return fmt.Sprintf("topic '%s'", t.value)
+	}
+
+	return fmt.Sprintf("'%s'", t.value)
+}
+
+type conditionLexer struct {
+	src string
+	pos int
+}
+
+func (l *conditionLexer) next() (conditionToken, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+
+	start := l.pos
+	if start == len(l.src) {
+		return conditionToken{kind: tokenEOF, pos: start}, nil
+	}
+
+	switch ch := l.src[start]; {
+	case ch == '(':
+		l.pos++
+		return conditionToken{kind: tokenLParen, value: "(", pos: start}, nil
+	case ch == ')':
+		l.pos++
+		return conditionToken{kind: tokenRParen, value: ")", pos: start}, nil
+	case ch == '!':
+		l.pos++
+		return conditionToken{kind: tokenNot, value: "!", pos: start}, nil
+	case strings.HasPrefix(l.src[start:], "&&"):
+		l.pos += 2
+		return conditionToken{kind: tokenAnd, value: "&&", pos: start}, nil
+	case strings.HasPrefix(l.src[start:], "||"):
+		l.pos += 2
+		return conditionToken{kind: tokenOr, value: "||", pos: start}, nil
+	case ch == '\'' || ch == '"':
+		return l.topic(ch)
+	case isConditionLetter(ch):
+		for l.pos < len(l.src) && isConditionLetter(l.src[l.pos]) {
+			l.pos++
+		}
+		return conditionToken{kind: tokenIdent, value: l.src[start:l.pos], pos: start}, nil
+	}
+
+	return conditionToken{}, &ConditionError{Pos: start, Msg: fmt.Sprintf("unexpected character '%c'", l.src[start])}
+}
+
+// topic scans a quoted topic name.
+func (l *conditionLexer) topic(quote byte) (conditionToken, error) {
+	start := l.pos
+	l.pos++
+
+	for l.pos < len(l.src) && l.src[l.pos] != quote {
+		if !isTopicChar(l.src[l.pos]) {
+			return conditionToken{}, &ConditionError{Pos: l.pos, Msg: fmt.Sprintf("invalid character '%c' in topic name", l.src[l.pos])}
+		}
+		l.pos++
+	}
+
+	if l.pos == len(l.src) {
+		return conditionToken{}, &ConditionError{Pos: start, Msg: "unterminated topic name"}
+	}
+
+	name := l.src[start+1 : l.pos]
+	l.pos++
+
+	if name == "" {
+		return conditionToken{}, &ConditionError{Pos: start, Msg: "empty topic name"}
+	}
+
+	return conditionToken{kind: tokenTopic, value: name, pos: start}, nil
+}
+
+func isConditionLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+}
+
+// isTopicChar reports whether ch may appear in a topic name.
+func isTopicChar(ch byte) bool {
+	return isConditionLetter(ch) || '0' <= ch && ch <= '9' || strings.IndexByte("-_.~%", ch) >= 0
+}
@@ -0,0 +1,193 @@
+package fcm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	valid := []struct {
+		expr     string
+		rendered string
+		topics   []string
+	}{
+		{"'TopicA' in topics", "'TopicA' in topics", []string{"TopicA"}},
+		{`"TopicA" in topics`, "'TopicA' in topics", []string{"TopicA"}},
+		{
+			"'TopicA' in topics && ('TopicB' in topics || 'TopicC' in topics)",
+			"'TopicA' in topics && ('TopicB' in topics || 'TopicC' in topics)",
+			[]string{"TopicA", "TopicB", "TopicC"},
+		},
+		{
+			"('a' in topics && 'b' in topics) || 'c' in topics",
+			"'a' in topics && 'b' in topics || 'c' in topics",
+			[]string{"a", "b", "c"},
+		},
+		{"!('a' in topics)", "!('a' in topics)", []string{"a"}},
+		{"!'a' in topics", "!('a' in topics)", []string{"a"}},
+		{"!('a' in topics || 'b' in topics)", "!('a' in topics || 'b' in topics)", []string{"a", "b"}},
+		{"'a-b_c.d~e%f' in topics", "'a-b_c.d~e%f' in topics", []string{"a-b_c.d~e%f"}},
+		{
+			"'a' in topics && 'b' in topics && 'c' in topics && 'd' in topics && 'e' in topics",
+			"'a' in topics && 'b' in topics && 'c' in topics && 'd' in topics && 'e' in topics",
+			[]string{"a", "b", "c", "d", "e"},
+		},
+	}
+
+	for _, tt := range valid {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := ParseCondition(tt.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if c.String() != tt.rendered {
+				t.Fatalf("expected: %v got: %v", tt.rendered, c.String())
+			}
+
+			if !reflect.DeepEqual(c.Topics(), tt.topics) {
+				t.Fatalf("expected: %v got: %v", tt.topics, c.Topics())
+			}
+
+			// the rendered condition parses to the same tree
+			again, err := ParseCondition(c.String())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if again.String() != c.String() {
+				t.Fatalf("expected: %v got: %v", c.String(), again.String())
+			}
+		})
+	}
+
+	invalid := []struct {
+		expr string
+		pos  int
+	}{
+		{"", 0},
+		{"'a' in", 6},
+		{"'a' in topic", 7},
+		{"'a' in topics &&", 16},
+		{"'a' in topics & 'b' in topics", 14},
+		{"('a' in topics", 14},
+		{"'a' in topics)", 13},
+		{"'a b' in topics", 2},
+		{"'' in topics", 0},
+		{"'a' in topics || 'b", 17},
+		{"a in topics", 0},
+		{"'a' in topics || 'b' in topics || 'c' in topics || 'd' in topics || 'e' in topics || 'f' in topics", 85},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCondition(tt.expr)
+
+			var condErr *ConditionError
+			if !errors.As(err, &condErr) {
+				t.Fatalf("expected *ConditionError, got: %v", err)
+			}
+
+			if condErr.Pos != tt.pos {
+				t.Fatalf("expected: %v got: %v (%v)", tt.pos, condErr.Pos, err)
+			}
+
+			if !errors.Is(err, ErrInvalidTarget) {
+				t.Fatalf("expected error to match %v", ErrInvalidTarget)
+			}
+		})
+	}
+}
+
+func TestConditionBuilder(t *testing.T) {
+	tests := []struct {
+		cond     Condition
+		expected string
+	}{
+		{Topic("a"), "'a' in topics"},
+		{Topic("a").And(Topic("b").Or(Topic("c"))), "'a' in topics && ('b' in topics || 'c' in topics)"},
+		{Topic("a").And(Topic("b")).Or(Topic("c")), "'a' in topics && 'b' in topics || 'c' in topics"},
+		{Topic("a").Or(Topic("b")).And(Topic("c")), "('a' in topics || 'b' in topics) && 'c' in topics"},
+		{Topic("a").Or(Topic("b")).Not(), "!('a' in topics || 'b' in topics)"},
+		{Topic("a").And(Topic("b").Not()), "'a' in topics && !('b' in topics)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if tt.cond.String() != tt.expected {
+				t.Fatalf("expected: %v got: %v", tt.expected, tt.cond.String())
+			}
+
+			if err := tt.cond.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			msg := &Message{Condition: tt.cond.String()}
+			if err := msg.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	invalid := []struct {
+		name string
+		cond Condition
+		pos  int
+	}{
+		{"empty topic", Topic("a").And(Topic("")), 17},
+		{"invalid character", Topic("a b"), 0},
+		{"topic prefix", Topic("a").Or(Topic("/topics/b")), 17},
+		{"too many topics", Topic("a").Or(Topic("b")).Or(Topic("c")).Or(Topic("d")).Or(Topic("e")).Or(Topic("f")), 85},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cond.Validate()
+
+			var condErr *ConditionError
+			if !errors.As(err, &condErr) {
+				t.Fatalf("expected *ConditionError, got: %v", err)
+			}
+
+			if condErr.Pos != tt.pos {
+				t.Fatalf("expected: %v got: %v (%v)", tt.pos, condErr.Pos, err)
+			}
+
+			if !errors.Is(err, ErrInvalidTarget) {
+				t.Fatalf("expected error to match %v", ErrInvalidTarget)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cond := "'stock' in topics && ('GOOG' in topics || !('AAPL' in topics))"
+
+	tests := []struct {
+		topics   []string
+		expected bool
+	}{
+		{[]string{"stock", "GOOG"}, true},
+		{[]string{"stock", "GOOG", "AAPL"}, true},
+		{[]string{"stock"}, true},
+		{[]string{"stock", "AAPL"}, false},
+		{[]string{"/topics/stock", "AAPL"}, false},
+		{[]string{"GOOG"}, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		got, err := Evaluate(cond, tt.topics)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != tt.expected {
+			t.Fatalf("%v: expected: %v got: %v", tt.topics, tt.expected, got)
+		}
+	}
+
+	if _, err := Evaluate("'stock' in", nil); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected: %v got: %v", ErrInvalidTarget, err)
+	}
+}
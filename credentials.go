@@ -0,0 +1,188 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// environment variable pointing to the Application Default Credentials file
+	credentialsEnvVar = "GOOGLE_APPLICATION_CREDENTIALS"
+
+	// name of the credentials file written by `gcloud auth application-default login`
+	wellKnownCredentialsFile = "application_default_credentials.json"
+)
+
+// environment variables that may hold the project id if it cannot be
+// detected from the credentials
+var projectIDEnvVars = []string{"GOOGLE_CLOUD_PROJECT", "GCLOUD_PROJECT"}
+
+// credentials holds the token source and the project id, if known, of a
+// credential source.
+type credentials struct {
+	tokenSource tokenSourceFunc
+	projectID   string
+}
+
+// credentialsSource resolves credentials when a Client is created. It is
+// passed the client so that it can use the configured HTTP client and endpoints.
+type credentialsSource func(ctx context.Context, c *Client) (*credentials, error)
+
+// credentialsFile is the subset of the fields of a JSON credentials file
+// needed to pick the flow and detect the project id.
+type credentialsFile struct {
+	Type           string `json:"type"`
+	ProjectID      string `json:"project_id"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// credentialsFromFile reads JSON credentials from filename.
+func credentialsFromFile(ctx context.Context, c *Client, filename string) (*credentials, error) {
+	jsonKey, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fcm: failed to read credentials file at: '%s'", filename)
+	}
+
+	return credentialsFromJSON(ctx, c, jsonKey)
+}
+
+// credentialsFromJSON creates credentials from a service account key, an
+// authorized user or an external account JSON file.
+func credentialsFromJSON(ctx context.Context, c *Client, jsonKey []byte) (*credentials, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(jsonKey, &f); err != nil {
+		return nil, errors.Wrapf(err, "fcm: failed to parse credentials")
+	}
+
+	switch f.Type {
+	case "service_account":
+		cfg, err := google.JWTConfigFromJSON(jsonKey, firebaseScope)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fcm: failed to get JWT config for the firebase.messaging scope")
+		}
+
+		return &credentials{
+			tokenSource: func(ctx context.Context) (oauth2.TokenSource, error) {
+				return cfg.TokenSource(withHTTPClient(ctx, c.client)), nil
+			},
+			projectID: f.ProjectID,
+		}, nil
+	case "authorized_user", "external_account":
+		if f.Type == "external_account" && c.stsEndpoint != "" {
+			var err error
+			if jsonKey, err = withTokenURL(jsonKey, c.stsEndpoint); err != nil {
+				return nil, err
+			}
+		}
+
+		// the token source is created again for every fetch, so that its
+		// requests are made with the context of the call
+		newTokenSource := func(ctx context.Context) (oauth2.TokenSource, error) {
+			creds, err := google.CredentialsFromJSON(withHTTPClient(ctx, c.client), jsonKey, firebaseScope)
+			if err != nil {
+				return nil, errors.Wrapf(err, "fcm: failed to get %s credentials", strings.Replace(f.Type, "_", " ", -1))
+			}
+
+			return creds.TokenSource, nil
+		}
+
+		if _, err := newTokenSource(ctx); err != nil {
+			return nil, err
+		}
+
+		return &credentials{
+			tokenSource: newTokenSource,
+			projectID:   f.QuotaProjectID,
+		}, nil
+	}
+
+	return nil, errors.Errorf("fcm: unsupported credentials type: '%s'", f.Type)
+}
+
+// withTokenURL returns the external account JSON with its token_url replaced
+// by tokenURL.
+func withTokenURL(jsonKey []byte, tokenURL string) ([]byte, error) {
+	var f map[string]interface{}
+	if err := json.Unmarshal(jsonKey, &f); err != nil {
+		return nil, errors.Wrapf(err, "fcm: failed to parse external account credentials")
+	}
+
+	f["token_url"] = tokenURL
+	return json.Marshal(f)
+}
+
+// metadataCredentials creates credentials backed by the metadata server of
+// GCE, GKE and other Google Cloud runtimes. The GCE_METADATA_HOST environment
+// variable overrides the address of the metadata server.
+func metadataCredentials(ctx context.Context, c *Client) (*credentials, error) {
+	projectID, err := metadata.ProjectID()
+	if err != nil {
+		return nil, errors.Wrapf(err, "fcm: failed to get the project id from the metadata server")
+	}
+
+	return &credentials{
+		tokenSource: staticTokenSourceFunc(google.ComputeTokenSource("", firebaseScope)),
+		projectID:   projectID,
+	}, nil
+}
+
+// defaultCredentials finds Application Default Credentials: the file named by
+// the GOOGLE_APPLICATION_CREDENTIALS environment variable, the well known
+// file written by the gcloud CLI, or else the metadata server of GCE, GKE
+// and other Google Cloud runtimes.
+func defaultCredentials(ctx context.Context, c *Client) (*credentials, error) {
+	if filename := os.Getenv(credentialsEnvVar); filename != "" {
+		return credentialsFromFile(ctx, c, filename)
+	}
+
+	filename := wellKnownCredentialsPath()
+	if filename != "" {
+		if _, err := os.Stat(filename); err == nil {
+			return credentialsFromFile(ctx, c, filename)
+		}
+	}
+
+	if metadata.OnGCE() {
+		return metadataCredentials(ctx, c)
+	}
+
+	return nil, errors.Errorf("fcm: could not find default credentials: %s is not set, "+
+		"the gcloud credentials file '%s' does not exist and the metadata server is not available; "+
+		"set %s, run `gcloud auth application-default login` or configure the credentials with an Option",
+		credentialsEnvVar, filename, credentialsEnvVar)
+}
+
+// wellKnownCredentialsPath returns the path of the credentials file written by the gcloud CLI.
+func wellKnownCredentialsPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", wellKnownCredentialsFile)
+	}
+
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "gcloud", wellKnownCredentialsFile)
+}
+
+// projectIDFromEnv returns the project id set in the environment, if any.
+func projectIDFromEnv() string {
+	for _, name := range projectIDEnvVars {
+		if id := os.Getenv(name); id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
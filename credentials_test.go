@@ -0,0 +1,363 @@
+package fcm
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func serviceAccountJSON(t *testing.T, projectID, tokenURI string) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     projectID,
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "fcm@" + projectID + ".iam.gserviceaccount.com",
+		"client_id":      "1234",
+		"token_uri":      tokenURI,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// roundTripperFunc is an http.RoundTripper implemented by a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	t.Run("credentials json", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/token":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "sa-token", "token_type": "Bearer", "expires_in": 3600}`))
+			case "/v1/projects/my-project/messages:send":
+				if got := r.Header.Get("Authorization"); got != "Bearer sa-token" {
+					t.Errorf("expected: %v got: %v", "Bearer sa-token", got)
+				}
+				w.Write([]byte(`{"name": "projects/my-project/messages/1"}`))
+			default:
+				t.Errorf("unexpected path: %v", r.URL.Path)
+			}
+		}))
+		defer srv.Close()
+
+		// the token request goes through the configured HTTP client too
+		var paths []string
+		httpClient := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			paths = append(paths, r.URL.Path)
+			return http.DefaultTransport.RoundTrip(r)
+		})}
+
+		c, err := NewClientWithOptions(
+			WithCredentialsJSON(serviceAccountJSON(t, "my-project", srv.URL+"/token")),
+			WithHTTPClient(httpClient),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.projectID != "my-project" {
+			t.Fatalf("expected: %v got: %v", "my-project", c.projectID)
+		}
+
+		c.endpoint = srv.URL + "/v1/projects/my-project/messages:send"
+		if _, err := c.SendContext(context.Background(), &SendRequest{Message: &Message{Topic: "cats"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{"/token", "/v1/projects/my-project/messages:send"}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Fatalf("expected: %v got: %v", expected, paths)
+		}
+	})
+
+	t.Run("cancelled token fetch", func(t *testing.T) {
+		aborted := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the server only notices the client going away once the body is read
+			r.ParseForm()
+			<-r.Context().Done()
+			close(aborted)
+		}))
+		defer srv.Close()
+
+		c, err := NewClientWithOptions(WithCredentialsJSON(serviceAccountJSON(t, "my-project", srv.URL+"/token")))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if _, err := c.tokenProvider.token(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected: %v got: %v", context.DeadlineExceeded, err)
+		}
+
+		// the token request itself is cancelled, not just abandoned
+		select {
+		case <-aborted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the token request to be cancelled")
+		}
+	})
+
+	t.Run("default credentials", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "fcm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, "sa.json")
+		if err := ioutil.WriteFile(filename, serviceAccountJSON(t, "adc-project", "http://localhost/token"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Setenv(credentialsEnvVar, filename)
+		defer os.Unsetenv(credentialsEnvVar)
+
+		c, err := NewClientWithOptions()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.endpoint != "https://fcm.googleapis.com/v1/projects/adc-project/messages:send" {
+			t.Fatalf("unexpected endpoint: %v", c.endpoint)
+		}
+	})
+
+	t.Run("token source", func(t *testing.T) {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+		if _, err := NewClientWithOptions(WithTokenSource(ts)); err == nil {
+			t.Fatal("expected error for undetectable project id, but got nil")
+		}
+
+		c, err := NewClientWithOptions(WithTokenSource(ts), WithProjectID("explicit"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.projectID != "explicit" {
+			t.Fatalf("expected: %v got: %v", "explicit", c.projectID)
+		}
+	})
+
+	t.Run("unsupported credentials", func(t *testing.T) {
+		_, err := NewClientWithOptions(WithCredentialsJSON([]byte(`{"type": "unknown"}`)))
+		if err == nil {
+			t.Fatal("expected error, but got nil")
+		}
+	})
+}
+
+func TestMetadataCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Error("expected Metadata-Flavor header")
+		}
+
+		switch r.URL.Path {
+		case "/computeMetadata/v1/project/project-id":
+			w.Write([]byte("gke-project"))
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			if r.URL.Query().Get("scopes") != firebaseScope {
+				t.Errorf("unexpected scopes: %v", r.URL.Query().Get("scopes"))
+			}
+			w.Write([]byte(`{"access_token": "gke-token", "expires_in": 3600, "token_type": "Bearer"}`))
+		default:
+			t.Errorf("unexpected path: %v", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	defer os.Unsetenv("GCE_METADATA_HOST")
+
+	c, err := NewClientWithOptions(WithMetadataCredentials())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.projectID != "gke-project" {
+		t.Fatalf("expected: %v got: %v", "gke-project", c.projectID)
+	}
+
+	token, err := c.tokenProvider.token(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "gke-token" {
+		t.Fatalf("expected: %v got: %v", "gke-token", token)
+	}
+}
+
+func TestExternalAccountCredentials(t *testing.T) {
+	t.Run("file source with impersonation", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "fcm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		subjectFile := filepath.Join(dir, "token")
+		if err := ioutil.WriteFile(subjectFile, []byte(`{"id_token": "oidc-token"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/sts":
+				r.ParseForm()
+				if r.Form.Get("subject_token") != "oidc-token" || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:token-exchange" {
+					t.Errorf("unexpected token exchange: %v", r.Form)
+				}
+				if scope := "https://www.googleapis.com/auth/cloud-platform"; r.Form.Get("scope") != scope {
+					t.Errorf("expected: %v got: %v", scope, r.Form.Get("scope"))
+				}
+				w.Write([]byte(`{"access_token": "sts-token", "token_type": "Bearer", "expires_in": 3600}`))
+			case "/impersonate":
+				if r.Header.Get("Authorization") != "Bearer sts-token" {
+					t.Errorf("unexpected authorization: %v", r.Header.Get("Authorization"))
+				}
+				w.Write([]byte(`{"accessToken": "sa-token", "expireTime": "2030-01-01T00:00:00Z"}`))
+			default:
+				t.Errorf("unexpected path: %v", r.URL.Path)
+			}
+		}))
+		defer srv.Close()
+
+		jsonKey, _ := json.Marshal(map[string]interface{}{
+			"type":                              "external_account",
+			"audience":                          "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/oidc",
+			"subject_token_type":                "urn:ietf:params:oauth:token-type:jwt",
+			"token_url":                         "https://sts.googleapis.com/v1/token",
+			"service_account_impersonation_url": srv.URL + "/impersonate",
+			"quota_project_id":                  "wif-project",
+			"credential_source": map[string]interface{}{
+				"file":   subjectFile,
+				"format": map[string]string{"type": "json", "subject_token_field_name": "id_token"},
+			},
+		})
+
+		c, err := NewClientWithOptions(WithCredentialsJSON(jsonKey), WithSTSEndpoint(srv.URL+"/sts"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if c.projectID != "wif-project" {
+			t.Fatalf("expected: %v got: %v", "wif-project", c.projectID)
+		}
+
+		token, err := c.tokenProvider.token(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != "sa-token" {
+			t.Fatalf("expected: %v got: %v", "sa-token", token)
+		}
+	})
+
+	t.Run("aws source", func(t *testing.T) {
+		for k, v := range map[string]string{
+			"AWS_REGION":            "us-east-2",
+			"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+			"AWS_SECRET_ACCESS_KEY": "secret",
+			"AWS_SESSION_TOKEN":     "session",
+		} {
+			os.Setenv(k, v)
+			defer os.Unsetenv(k)
+		}
+
+		audience := "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/aws"
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			if r.Form.Get("scope") != firebaseScope {
+				t.Errorf("expected: %v got: %v", firebaseScope, r.Form.Get("scope"))
+			}
+
+			raw, err := url.QueryUnescape(r.Form.Get("subject_token"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var token struct {
+				URL     string
+				Method  string
+				Headers []struct{ Key, Value string }
+			}
+			if err := json.Unmarshal([]byte(raw), &token); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token.URL != "https://sts.us-east-2.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15" || token.Method != "POST" {
+				t.Errorf("unexpected subject token: %+v", token)
+			}
+
+			headers := make(map[string]string)
+			for _, h := range token.Headers {
+				headers[h.Key] = h.Value
+			}
+			if headers["X-Goog-Cloud-Target-Resource"] != audience || headers["X-Amz-Security-Token"] != "session" {
+				t.Errorf("unexpected headers: %v", headers)
+			}
+			if !strings.HasPrefix(headers["Authorization"], "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+				t.Errorf("unexpected authorization: %v", headers["Authorization"])
+			}
+
+			w.Write([]byte(`{"access_token": "sts-token", "token_type": "Bearer", "expires_in": 3600}`))
+		}))
+		defer srv.Close()
+
+		jsonKey, _ := json.Marshal(map[string]interface{}{
+			"type":               "external_account",
+			"audience":           audience,
+			"subject_token_type": "urn:ietf:params:aws:token-type:aws4_request",
+			"token_url":          srv.URL,
+			"credential_source": map[string]string{
+				"environment_id":                 "aws1",
+				"regional_cred_verification_url": "https://sts.{region}.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+			},
+		})
+
+		c, err := NewClientWithOptions(WithCredentialsJSON(jsonKey), WithProjectID("aws-project"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		token, err := c.tokenProvider.token(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != "sts-token" {
+			t.Fatalf("expected: %v got: %v", "sts-token", token)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package fcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	fcmErrorType   = "type.googleapis.com/google.firebase.fcm.v1.FcmError"
+	badRequestType = "type.googleapis.com/google.rpc.BadRequest"
+)
+
+// ErrorCode represents the error codes returned by the FCM v1 API.
+// https://firebase.google.com/docs/reference/fcm/rest/v1/ErrorCode
+type ErrorCode string
+
+const (
+	// ErrorCodeUnspecified is used when no more specific error code is known.
+	ErrorCodeUnspecified ErrorCode = "UNSPECIFIED_ERROR"
+
+	// ErrorCodeInvalidArgument indicates that the request parameters were invalid.
+	ErrorCodeInvalidArgument ErrorCode = "INVALID_ARGUMENT"
+
+	// ErrorCodeUnregistered indicates that the registration token is no longer valid,
+	// e.g. because the app was uninstalled. The token should be discarded.
+	ErrorCodeUnregistered ErrorCode = "UNREGISTERED"
+
+	// ErrorCodeSenderIDMismatch indicates that the authenticated sender ID is different
+	// from the sender ID the registration token is tied to.
+	ErrorCodeSenderIDMismatch ErrorCode = "SENDER_ID_MISMATCH"
+
+	// ErrorCodeQuotaExceeded indicates that the sending limit for the message target was exceeded.
+	ErrorCodeQuotaExceeded ErrorCode = "QUOTA_EXCEEDED"
+
+	// ErrorCodeUnavailable indicates that the server is overloaded.
+	ErrorCodeUnavailable ErrorCode = "UNAVAILABLE"
+
+	// ErrorCodeInternal indicates that an unknown internal error occurred.
+	ErrorCodeInternal ErrorCode = "INTERNAL"
+
+	// ErrorCodeThirdPartyAuthError indicates that the APNs certificate or web push
+	// auth key was invalid or missing.
+	ErrorCodeThirdPartyAuthError ErrorCode = "THIRD_PARTY_AUTH_ERROR"
+)
+
+// FieldViolation describes a single bad request field, as reported by the
+// google.rpc.BadRequest error detail.
+type FieldViolation struct {
+	// A path leading to a field in the request body, e.g. "message.token".
+	Field string `json:"field"`
+
+	// A description of why the request element is bad.
+	Description string `json:"description"`
+}
+
+// Error is returned when the FCM server responds with a non-200 status. It is
+// decoded from the google.rpc.Status body of the response, or from the
+// error reason returned by the Instance ID service.
+type Error struct {
+	// HTTP status code of the response. Zero for errors of individual items
+	// of a successful batch response, e.g. of ImportAPNsTokens.
+	StatusCode int
+
+	// FCM error code. If the response carries no FcmError detail, it is
+	// derived from the canonical status or the HTTP status code.
+	Code ErrorCode
+
+	// Canonical status of the response, e.g. "NOT_FOUND".
+	Status string
+
+	// Developer facing error message.
+	Message string
+
+	// Fields of the request that were rejected by the server.
+	FieldViolations []FieldViolation
+
+	// How long the server asked to wait before retrying, as sent in the
+	// Retry-After header of 429 and 503 responses.
+	RetryAfter time.Duration
+
+	// Raw body of the response.
+	Body string
+
+	// Dump of the request, for debugging purposes. The Authorization header
+	// is left out.
+	RequestDump string
+
+	// Dump of the response, for debugging purposes.
+	ResponseDump string
+}
+
+// HttpError contains the dump of the request and response for debugging purposes.
+//
+// Deprecated: non-200 responses are returned as *Error, which holds the
+// dumps in its RequestDump and ResponseDump fields. errors.As still finds an
+// HttpError in an *Error, with Err set to the *Error, but a type assertion
+// such as err.(fcm.HttpError) no longer succeeds.
+type HttpError struct {
+	RequestDump  string
+	ResponseDump string
+	Err          error
+}
+
+func (fcmError HttpError) Error() string {
+	return fcmError.Err.Error()
+}
+
+// Unwrap returns Err.
+func (fcmError HttpError) Unwrap() error {
+	return fcmError.Err
+}
+
+// As sets target to an HttpError holding the dumps of e, if target is an
+// *HttpError, so that code written for HttpError keeps working.
+func (e *Error) As(target interface{}) bool {
+	t, ok := target.(*HttpError)
+	if !ok {
+		return false
+	}
+
+	*t = HttpError{RequestDump: e.RequestDump, ResponseDump: e.ResponseDump, Err: e}
+	return true
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "fcm: %s", e.Code)
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (%d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+
+	for _, v := range e.FieldViolations {
+		fmt.Fprintf(&b, "; %s: %s", v.Field, v.Description)
+	}
+
+	return b.String()
+}
+
+// rpcStatus is the JSON representation of a google.rpc.Status error response.
+type rpcStatus struct {
+	Error struct {
+		Code    int               `json:"code"`
+		Message string            `json:"message"`
+		Status  string            `json:"status"`
+		Details []json.RawMessage `json:"details"`
+	} `json:"error"`
+}
+
+// rpcDetail holds the fields of the error details we know how to decode.
+type rpcDetail struct {
+	Type            string           `json:"@type"`
+	ErrorCode       ErrorCode        `json:"errorCode"`
+	FieldViolations []FieldViolation `json:"fieldViolations"`
+}
+
+// newError builds an Error out of a non-200 response. The body of the
+// response is consumed.
+func newError(resp *http.Response) *Error {
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Body:       string(body),
+	}
+
+	// the Instance ID service reports errors as {"error": "reason"}
+	var iidStatus struct {
+		Error string `json:"error"`
+	}
+
+	var status rpcStatus
+	if err := json.Unmarshal(body, &iidStatus); err == nil && iidStatus.Error != "" {
+		e.Message = iidStatus.Error
+	} else if err := json.Unmarshal(body, &status); err == nil {
+		if status.Error.Message != "" {
+			e.Message = status.Error.Message
+		}
+		e.Status = status.Error.Status
+
+		for _, raw := range status.Error.Details {
+			var detail rpcDetail
+			if err := json.Unmarshal(raw, &detail); err != nil {
+				continue
+			}
+
+			switch detail.Type {
+			case fcmErrorType:
+				e.Code = detail.ErrorCode
+			case badRequestType:
+				e.FieldViolations = append(e.FieldViolations, detail.FieldViolations...)
+			}
+		}
+	}
+
+	if e.Code == "" {
+		e.Code = errorCodeFromStatus(e.Status, e.StatusCode)
+	}
+
+	return e
+}
+
+// errorCodeFromStatus maps a canonical status, or failing that an HTTP status
+// code, to the closest FCM error code.
+func errorCodeFromStatus(status string, statusCode int) ErrorCode {
+	switch status {
+	case "INVALID_ARGUMENT":
+		return ErrorCodeInvalidArgument
+	case "NOT_FOUND":
+		return ErrorCodeUnregistered
+	case "RESOURCE_EXHAUSTED":
+		return ErrorCodeQuotaExceeded
+	case "UNAVAILABLE":
+		return ErrorCodeUnavailable
+	case "INTERNAL":
+		return ErrorCodeInternal
+	}
+
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrorCodeInvalidArgument
+	case http.StatusNotFound:
+		return ErrorCodeUnregistered
+	case http.StatusTooManyRequests:
+		return ErrorCodeQuotaExceeded
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	case http.StatusInternalServerError:
+		return ErrorCodeInternal
+	}
+
+	return ErrorCodeUnspecified
+}
+
+// hasCode reports whether err is an *Error with the given code.
+func hasCode(err error, code ErrorCode) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// IsInvalidArgument reports whether err is an INVALID_ARGUMENT error.
+func IsInvalidArgument(err error) bool {
+	return hasCode(err, ErrorCodeInvalidArgument)
+}
+
+// IsUnregistered reports whether err is an UNREGISTERED error. The
+// registration token the message was sent to should be discarded.
+func IsUnregistered(err error) bool {
+	return hasCode(err, ErrorCodeUnregistered)
+}
+
+// IsSenderIDMismatch reports whether err is a SENDER_ID_MISMATCH error.
+func IsSenderIDMismatch(err error) bool {
+	return hasCode(err, ErrorCodeSenderIDMismatch)
+}
+
+// IsQuotaExceeded reports whether err is a QUOTA_EXCEEDED error.
+func IsQuotaExceeded(err error) bool {
+	return hasCode(err, ErrorCodeQuotaExceeded)
+}
+
+// IsUnavailable reports whether err is an UNAVAILABLE error.
+func IsUnavailable(err error) bool {
+	return hasCode(err, ErrorCodeUnavailable)
+}
+
+// IsInternal reports whether err is an INTERNAL error.
+func IsInternal(err error) bool {
+	return hasCode(err, ErrorCodeInternal)
+}
+
+// IsThirdPartyAuthError reports whether err is a THIRD_PARTY_AUTH_ERROR error.
+func IsThirdPartyAuthError(err error) bool {
+	return hasCode(err, ErrorCodeThirdPartyAuthError)
+}
+
+// IsRetryable reports whether err is an FCM error that may succeed if the
+// request is sent again later: UNAVAILABLE, INTERNAL, QUOTA_EXCEEDED or any
+// 5xx or 429 response.
+func IsRetryable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	switch e.Code {
+	case ErrorCodeUnavailable, ErrorCodeInternal, ErrorCodeQuotaExceeded:
+		return true
+	}
+
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
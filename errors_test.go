@@ -0,0 +1,147 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	send := func(t *testing.T, status int, body string) error {
+		t.Helper()
+
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		})
+		defer srv.Close()
+
+		_, err := c.SendContext(context.Background(), &SendRequest{Message: &Message{Topic: "cats"}})
+		if err == nil {
+			t.Fatal("expected error, but got nil")
+		}
+		return err
+	}
+
+	t.Run("fcm error detail", func(t *testing.T) {
+		err := send(t, http.StatusNotFound, `{
+			"error": {
+				"code": 404,
+				"message": "Requested entity was not found.",
+				"status": "NOT_FOUND",
+				"details": [{
+					"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+					"errorCode": "UNREGISTERED"
+				}]
+			}
+		}`)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected *Error, got %T", err)
+		}
+		if e.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected: %v got: %v", http.StatusNotFound, e.StatusCode)
+		}
+		if e.Code != ErrorCodeUnregistered {
+			t.Fatalf("expected: %v got: %v", ErrorCodeUnregistered, e.Code)
+		}
+		if e.Message != "Requested entity was not found." {
+			t.Fatalf("unexpected message: %v", e.Message)
+		}
+		if !IsUnregistered(err) || IsRetryable(err) {
+			t.Fatalf("unexpected predicates for %v", err)
+		}
+	})
+
+	t.Run("field violations", func(t *testing.T) {
+		err := send(t, http.StatusBadRequest, `{
+			"error": {
+				"code": 400,
+				"message": "Invalid value at 'message.android.ttl'",
+				"status": "INVALID_ARGUMENT",
+				"details": [
+					{
+						"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+						"errorCode": "INVALID_ARGUMENT"
+					},
+					{
+						"@type": "type.googleapis.com/google.rpc.BadRequest",
+						"fieldViolations": [{
+							"field": "message.android.ttl",
+							"description": "Invalid value"
+						}]
+					}
+				]
+			}
+		}`)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected *Error, got %T", err)
+		}
+		if len(e.FieldViolations) != 1 || e.FieldViolations[0].Field != "message.android.ttl" {
+			t.Fatalf("unexpected field violations: %v", e.FieldViolations)
+		}
+		if !IsInvalidArgument(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeInvalidArgument, e.Code)
+		}
+	})
+
+	t.Run("code from status", func(t *testing.T) {
+		err := send(t, http.StatusServiceUnavailable, `{"error": {"code": 503, "status": "UNAVAILABLE"}}`)
+		if !IsUnavailable(err) || !IsRetryable(err) {
+			t.Fatalf("unexpected predicates for %v", err)
+		}
+	})
+
+	t.Run("non json body", func(t *testing.T) {
+		err := send(t, http.StatusTooManyRequests, `slow down`)
+		if !IsQuotaExceeded(err) || !IsRetryable(err) {
+			t.Fatalf("unexpected predicates for %v", err)
+		}
+	})
+
+	t.Run("dumps", func(t *testing.T) {
+		err := send(t, http.StatusBadRequest, `{"error": {"code": 400, "status": "INVALID_ARGUMENT"}}`)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected *Error, got %T", err)
+		}
+		if !strings.Contains(e.RequestDump, `"topic":"cats"`) || strings.Contains(e.RequestDump, "test-token") {
+			t.Fatalf("unexpected request dump: %v", e.RequestDump)
+		}
+		if !strings.Contains(e.ResponseDump, "400 Bad Request") || !strings.Contains(e.ResponseDump, "INVALID_ARGUMENT") {
+			t.Fatalf("unexpected response dump: %v", e.ResponseDump)
+		}
+		if e.Status != "INVALID_ARGUMENT" {
+			t.Fatalf("expected: %v got: %v", "INVALID_ARGUMENT", e.Status)
+		}
+
+		// code written for the deprecated HttpError keeps working
+		var httpErr HttpError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("expected HttpError, got %T", err)
+		}
+		if httpErr.RequestDump != e.RequestDump || httpErr.ResponseDump != e.ResponseDump || httpErr.Err != e {
+			t.Fatalf("unexpected HttpError: %+v", httpErr)
+		}
+		if httpErr.Error() != e.Error() || !IsInvalidArgument(httpErr) {
+			t.Fatalf("unexpected HttpError: %v", httpErr)
+		}
+	})
+
+	t.Run("wrapped error", func(t *testing.T) {
+		err := fmt.Errorf("sending: %w", &Error{Code: ErrorCodeSenderIDMismatch, StatusCode: http.StatusForbidden})
+		if !IsSenderIDMismatch(err) {
+			t.Fatalf("expected %v to match", err)
+		}
+		if IsThirdPartyAuthError(err) || IsRetryable(err) {
+			t.Fatalf("unexpected predicates for %v", err)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/tevjef/go-fcm"
 )
@@ -26,17 +27,13 @@ func main() {
 			ThreadID:         "my-thread-id",
 			ContentAvailable: int(fcm.ApnsContentAvailable),
 		},
+		// optional additional data in APNS message.
+		Custom: map[string]interface{}{
+			"acme1": "bar",
+			"acme2": []string{"bang", "whiz"},
+		},
 	}
 
-	payload, err := apnsPayload.ToMap()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// optionall additional data in APNS message.
-	payload["acme1"] = "bar"
-	payload["acme2"] = []string{"bang", "whiz"}
-
 	msg := &fcm.SendRequest{
 		ValidateOnly: true,
 		Message: &fcm.Message{
@@ -49,12 +46,12 @@ func main() {
 			},
 			Apns: &fcm.ApnsConfig{
 				Headers: &fcm.ApnsHeaders{
-					Expiration: "14567890",
+					Expiration: time.Unix(14567890, 0),
 					Priority:   string(fcm.ApnsHighPriority),
 					Topic:      "my-topic",
 					CollapseID: "my-collapse-id",
 				},
-				Payload: payload,
+				TypedPayload: apnsPayload,
 			},
 			Android: &fcm.AndroidConfig{
 				CollapseKey: "my-collapse-key",
@@ -63,12 +60,12 @@ func main() {
 				Data: map[string]string{
 					"acme1": "bar",
 				},
-				RestrictedPackageName: "com.github.go-fcm",
+				RestrictedPackageName: "com.github.gofcm",
 				Notification: &fcm.AndroidNotification{
 					Title:        "FCM Message",
 					Body:         "This is a Firebase Cloud Messaging Topic Message!",
 					Icon:         "ic_notification",
-					Color:        "#rrggbb",
+					Color:        "#4285f4",
 					Sound:        "res_raw_notification_sound.mp3",
 					Tag:          "my-notification-tag",
 					ClickAction:  "MainActivity",
@@ -0,0 +1,514 @@
+// Package fcmtest provides an in-process fake of the FCM HTTP v1 API for
+// integration tests.
+//
+// The Server implements projects/*/messages:send, an OAuth2 token endpoint and
+// the Instance ID topic management endpoints. It records every received
+// message and lets tests script responses per registration token or topic:
+//
+//	srv := fcmtest.NewServer()
+//	defer srv.Close()
+//
+//	srv.RespondToToken("stale-token", fcmtest.Unregistered())
+//
+//	client, err := srv.NewClient()
+//	...
+//	msgs := srv.Messages()
+package fcmtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-fcm"
+)
+
+const (
+	// DefaultProjectID is the project id of a Server.
+	DefaultProjectID = "fcmtest"
+
+	// DefaultAccessToken is the access token issued by the token endpoint of a Server.
+	DefaultAccessToken = "fcmtest-access-token"
+)
+
+// Response scripts how the Server responds to a message or topic
+// management request.
+type Response struct {
+	// HTTP status code of the response. Zero means 200 OK.
+	StatusCode int
+
+	// FCM error code reported in the FcmError detail of the response.
+	ErrorCode fcm.ErrorCode
+
+	// Error message of the response.
+	Message string
+
+	// Value of the Retry-After header. The header carries whole seconds, so
+	// the value is rounded up to the next second.
+	RetryAfter time.Duration
+
+	// Delay before the response is sent.
+	Latency time.Duration
+
+	// Number of requests the response is used for, after which the Server
+	// responds normally again. Zero means every request.
+	Times int
+}
+
+// Unregistered returns a 404 UNREGISTERED Response.
+func Unregistered() Response {
+	return Response{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  fcm.ErrorCodeUnregistered,
+		Message:    "Requested entity was not found.",
+	}
+}
+
+// InvalidArgument returns a 400 INVALID_ARGUMENT Response.
+func InvalidArgument(message string) Response {
+	return Response{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  fcm.ErrorCodeInvalidArgument,
+		Message:    message,
+	}
+}
+
+// QuotaExceeded returns a 429 QUOTA_EXCEEDED Response with a Retry-After header.
+func QuotaExceeded(retryAfter time.Duration) Response {
+	return Response{
+		StatusCode: http.StatusTooManyRequests,
+		ErrorCode:  fcm.ErrorCodeQuotaExceeded,
+		Message:    "Quota exceeded.",
+		RetryAfter: retryAfter,
+	}
+}
+
+// Unavailable returns a 503 UNAVAILABLE Response.
+func Unavailable() Response {
+	return Response{
+		StatusCode: http.StatusServiceUnavailable,
+		ErrorCode:  fcm.ErrorCodeUnavailable,
+		Message:    "The service is currently unavailable.",
+	}
+}
+
+// Latency returns a successful Response that is delayed by d.
+func Latency(d time.Duration) Response {
+	return Response{Latency: d}
+}
+
+// Server is a fake FCM server backed by an httptest.Server.
+type Server struct {
+	// URL of the server, e.g. "http://127.0.0.1:1234".
+	URL string
+
+	// Project id the server accepts messages for.
+	ProjectID string
+
+	// Access token the server issues and expects as bearer token.
+	AccessToken string
+
+	srv             *httptest.Server
+	credentialsJSON []byte
+
+	mu             sync.Mutex
+	messages       []*fcm.Message
+	tokenResponses map[string]*Response
+	topicResponses map[string]*Response
+	subscriptions  map[string]map[string]bool
+}
+
+// NewServer starts a Server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		ProjectID:      DefaultProjectID,
+		AccessToken:    DefaultAccessToken,
+		tokenResponses: make(map[string]*Response),
+		topicResponses: make(map[string]*Response),
+		subscriptions:  make(map[string]map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/v1/projects/", s.handleSend)
+	mux.HandleFunc("/iid/v1:batchAdd", s.handleTopicManagement(true))
+	mux.HandleFunc("/iid/v1:batchRemove", s.handleTopicManagement(false))
+
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	s.credentialsJSON = serviceAccountJSON(s.ProjectID, s.URL+"/token")
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Endpoint returns the messages:send endpoint of the server.
+func (s *Server) Endpoint() string {
+	return fmt.Sprintf("%s/v1/projects/%s/messages:send", s.URL, s.ProjectID)
+}
+
+// CredentialsJSON returns service account credentials whose token endpoint
+// is the server.
+func (s *Server) CredentialsJSON() []byte {
+	return s.credentialsJSON
+}
+
+// Options returns the options that point a Client at the server.
+func (s *Server) Options() []fcm.Option {
+	return []fcm.Option{
+		fcm.WithCredentialsJSON(s.credentialsJSON),
+		fcm.WithEndpoint(s.Endpoint()),
+		fcm.WithIIDEndpoint(s.URL),
+		fcm.WithHTTPClient(s.srv.Client()),
+	}
+}
+
+// NewClient creates a Client that sends to the server. opts are applied after
+// the options returned by Options.
+func (s *Server) NewClient(opts ...fcm.Option) (*fcm.Client, error) {
+	return fcm.NewClientWithOptions(append(s.Options(), opts...)...)
+}
+
+// RespondToToken scripts the response to messages sent to, and topic
+// management requests for, a registration token.
+func (s *Server) RespondToToken(token string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenResponses[token] = &r
+}
+
+// RespondToTopic scripts the response to messages sent to, and topic
+// management requests for, a topic.
+func (s *Server) RespondToTopic(topic string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topicResponses[topic] = &r
+}
+
+// Messages returns the messages received so far, including ones that were
+// answered with an error.
+func (s *Server) Messages() []*fcm.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]*fcm.Message, len(s.messages))
+	copy(msgs, s.messages)
+	return msgs
+}
+
+// Subscribers returns the registration tokens subscribed to topic.
+func (s *Server) Subscribers(topic string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []string
+	for token := range s.subscriptions[topic] {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// Reset forgets the received messages, scripted responses and subscriptions.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+	s.tokenResponses = make(map[string]*Response)
+	s.topicResponses = make(map[string]*Response)
+	s.subscriptions = make(map[string]map[string]bool)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": s.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != fmt.Sprintf("/v1/projects/%s/messages:send", s.ProjectID) {
+		writeError(w, Response{StatusCode: http.StatusNotFound, Message: "unknown endpoint " + r.URL.Path})
+		return
+	}
+
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req fcm.SendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, InvalidArgument(err.Error()))
+		return
+	}
+
+	if req.Message == nil {
+		writeError(w, InvalidArgument("message is required"))
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, req.Message)
+	id := len(s.messages)
+
+	resp := s.takeResponse(s.tokenResponses, req.Message.Token)
+	if resp == nil {
+		resp = s.takeResponse(s.topicResponses, req.Message.Topic)
+	}
+	s.mu.Unlock()
+
+	if resp != nil {
+		if !sleep(r, resp.Latency) {
+			return
+		}
+
+		if resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+			writeError(w, *resp)
+			return
+		}
+	}
+
+	if err := req.Message.Validate(); err != nil {
+		writeError(w, InvalidArgument(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"name": fmt.Sprintf("projects/%s/messages/%d", s.ProjectID, id),
+	})
+}
+
+func (s *Server) handleTopicManagement(subscribe bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !s.authorized(w, r) {
+			return
+		}
+
+		if r.Header.Get("access_token_auth") != "true" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "access_token_auth header is required"})
+			return
+		}
+
+		var req struct {
+			To                 string   `json:"to"`
+			RegistrationTokens []string `json:"registration_tokens"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		topic := strings.TrimPrefix(req.To, "/topics/")
+		if topic == "" || topic == req.To {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidTopicName"})
+			return
+		}
+
+		s.mu.Lock()
+		resp := s.takeResponse(s.topicResponses, topic)
+		s.mu.Unlock()
+
+		if resp != nil {
+			if !sleep(r, resp.Latency) {
+				return
+			}
+
+			if resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+				writeError(w, *resp)
+				return
+			}
+		}
+
+		results := make([]map[string]string, len(req.RegistrationTokens))
+
+		s.mu.Lock()
+		if s.subscriptions[topic] == nil {
+			s.subscriptions[topic] = make(map[string]bool)
+		}
+
+		for i, token := range req.RegistrationTokens {
+			results[i] = map[string]string{}
+
+			if resp := s.takeResponse(s.tokenResponses, token); resp != nil && resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+				results[i]["error"] = topicErrorReason(resp.ErrorCode)
+				continue
+			}
+
+			if subscribe {
+				s.subscriptions[topic][token] = true
+			} else {
+				delete(s.subscriptions[topic], token)
+			}
+		}
+		s.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+	}
+}
+
+// authorized checks the bearer token of the request and writes an
+// UNAUTHENTICATED error if it is wrong.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") == "Bearer "+s.AccessToken {
+		return true
+	}
+
+	writeError(w, Response{
+		StatusCode: http.StatusUnauthorized,
+		Message:    "Request had invalid authentication credentials.",
+	})
+	return false
+}
+
+// takeResponse returns the scripted response for key, if any, and counts its
+// use. s.mu must be held.
+func (s *Server) takeResponse(responses map[string]*Response, key string) *Response {
+	if key == "" {
+		return nil
+	}
+
+	resp, ok := responses[key]
+	if !ok {
+		return nil
+	}
+
+	if resp.Times > 0 {
+		resp.Times--
+		if resp.Times == 0 {
+			delete(responses, key)
+		}
+	}
+
+	r := *resp
+	return &r
+}
+
+// sleep waits for d, returning false if the request is cancelled first.
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// topicErrorReason maps an FCM error code to the reason reported by the
+// Instance ID service.
+func topicErrorReason(code fcm.ErrorCode) string {
+	switch code {
+	case fcm.ErrorCodeUnregistered:
+		return "NOT_FOUND"
+	case fcm.ErrorCodeInvalidArgument:
+		return "INVALID_ARGUMENT"
+	case fcm.ErrorCodeQuotaExceeded:
+		return "TOO_MANY_TOPICS"
+	}
+	return "INTERNAL"
+}
+
+// canonicalStatus returns the google.rpc canonical status of an HTTP status code.
+func canonicalStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	}
+	return "INTERNAL"
+}
+
+// writeError writes a google.rpc.Status error response.
+func writeError(w http.ResponseWriter, r Response) {
+	var details []interface{}
+	if r.ErrorCode != "" {
+		details = append(details, map[string]string{
+			"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+			"errorCode": string(r.ErrorCode),
+		})
+	}
+
+	if r.RetryAfter > 0 {
+		seconds := (r.RetryAfter + time.Second - 1) / time.Second
+		w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+	}
+
+	writeJSON(w, r.StatusCode, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    r.StatusCode,
+			"message": r.Message,
+			"status":  canonicalStatus(r.StatusCode),
+			"details": details,
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// serviceAccountJSON generates service account credentials with a fresh
+// private key and the given token endpoint.
+func serviceAccountJSON(projectID, tokenURI string) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("fcmtest: failed to generate key: %v", err))
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("fcmtest: failed to marshal key: %v", err))
+	}
+
+	b, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     projectID,
+		"private_key_id": "fcmtest",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "fcmtest@" + projectID + ".iam.gserviceaccount.com",
+		"client_id":      "fcmtest",
+		"token_uri":      tokenURI,
+	})
+	return b
+}
@@ -0,0 +1,186 @@
+package fcmtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-fcm"
+	"golang.org/x/oauth2"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	noRetry := fcm.WithRetryPolicy(&fcm.RetryPolicy{MaxAttempts: 1, Multiplier: 1})
+
+	t.Run("send", func(t *testing.T) {
+		srv.Reset()
+
+		client, err := srv.NewClient(noRetry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		msg, err := client.SendContext(context.Background(), &fcm.SendRequest{
+			Message: &fcm.Message{Token: "token", Data: map[string]string{"hello": "world"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if msg.MessageID() != "1" {
+			t.Fatalf("expected: %v got: %v", "1", msg.MessageID())
+		}
+
+		msgs := srv.Messages()
+		if len(msgs) != 1 {
+			t.Fatalf("expected: %v got: %v", 1, len(msgs))
+		}
+		if msgs[0].Token != "token" || msgs[0].Data["hello"] != "world" {
+			t.Fatalf("unexpected message: %+v", msgs[0])
+		}
+	})
+
+	t.Run("wrong bearer token", func(t *testing.T) {
+		srv.Reset()
+
+		client, err := fcm.NewClientWithOptions(
+			fcm.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "wrong"})),
+			fcm.WithEndpoint(srv.Endpoint()),
+			noRetry,
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = client.SendContext(context.Background(), &fcm.SendRequest{Message: &fcm.Message{Topic: "cats"}})
+		var e *fcm.Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected: %v got: %v", http.StatusUnauthorized, err)
+		}
+	})
+
+	t.Run("scripted token", func(t *testing.T) {
+		srv.Reset()
+		srv.RespondToToken("stale", Unregistered())
+
+		client, err := srv.NewClient(noRetry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = client.SendContext(context.Background(), &fcm.SendRequest{Message: &fcm.Message{Token: "stale"}})
+		if !fcm.IsUnregistered(err) {
+			t.Fatalf("expected: %v got: %v", fcm.ErrorCodeUnregistered, err)
+		}
+
+		if len(srv.Messages()) != 1 {
+			t.Fatalf("expected: %v got: %v", 1, len(srv.Messages()))
+		}
+	})
+
+	t.Run("scripted topic with retry after", func(t *testing.T) {
+		srv.Reset()
+		// rounded up to the whole second the header can carry
+		r := QuotaExceeded(500 * time.Millisecond)
+		r.Times = 1
+		srv.RespondToTopic("cats", r)
+
+		client, err := srv.NewClient(noRetry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		req := &fcm.SendRequest{Message: &fcm.Message{Topic: "cats"}}
+
+		_, err = client.SendContext(context.Background(), req)
+		var e *fcm.Error
+		if !errors.As(err, &e) || e.Code != fcm.ErrorCodeQuotaExceeded {
+			t.Fatalf("expected: %v got: %v", fcm.ErrorCodeQuotaExceeded, err)
+		}
+		if e.RetryAfter != time.Second {
+			t.Fatalf("expected: %v got: %v", time.Second, e.RetryAfter)
+		}
+
+		// the response was scripted for one request only
+		if _, err := client.SendContext(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		srv.Reset()
+		srv.RespondToToken("slow", Latency(time.Second))
+
+		client, err := srv.NewClient(noRetry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = client.SendContext(ctx, &fcm.SendRequest{Message: &fcm.Message{Token: "slow"}})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("topic management", func(t *testing.T) {
+		srv.Reset()
+		srv.RespondToToken("stale", Unregistered())
+
+		client, err := srv.NewClient(noRetry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resp, err := client.SubscribeToTopic(context.Background(), []string{"a", "stale", "b"}, "cats")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.SuccessCount != 2 || resp.FailureCount != 1 {
+			t.Fatalf("expected: %v/%v got: %v/%v", 2, 1, resp.SuccessCount, resp.FailureCount)
+		}
+		if resp.Errors[0].Index != 1 || resp.Errors[0].Reason != "NOT_FOUND" {
+			t.Fatalf("unexpected error: %+v", resp.Errors[0])
+		}
+		if got := len(srv.Subscribers("cats")); got != 2 {
+			t.Fatalf("expected: %v got: %v", 2, got)
+		}
+
+		if _, err := client.UnsubscribeFromTopic(context.Background(), []string{"a"}, "/topics/cats"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := srv.Subscribers("cats"); len(got) != 1 || got[0] != "b" {
+			t.Fatalf("expected: %v got: %v", []string{"b"}, got)
+		}
+	})
+	t.Run("scripted topic management", func(t *testing.T) {
+		srv.Reset()
+		r := Unavailable()
+		r.Times = 1
+		srv.RespondToTopic("cats", r)
+
+		client, err := srv.NewClient(noRetry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = client.SubscribeToTopic(context.Background(), []string{"a", "b"}, "cats")
+		if !fcm.IsUnavailable(err) {
+			t.Fatalf("expected: %v got: %v", fcm.ErrorCodeUnavailable, err)
+		}
+		if got := len(srv.Subscribers("cats")); got != 0 {
+			t.Fatalf("expected: %v got: %v", 0, got)
+		}
+
+		// the response was scripted for one request only
+		if _, err := client.UnsubscribeFromTopic(context.Background(), []string{"a"}, "cats"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	iidDateLayout = "2006-01-02"
+
+	// maximum number of APNs tokens per batchImport request
+	maxApnsImportTokens = 100
+)
+
+var (
+	// ErrInvalidToken occurs if a registration token is empty or malformed.
+	ErrInvalidToken = errors.New("registration token is invalid")
+
+	// ErrInvalidBundleID occurs if APNs tokens are imported without a bundle ID.
+	ErrInvalidBundleID = errors.New("bundle id is invalid")
+)
+
+// TokenInfo describes a registration token, as reported by the Instance ID service.
+// https://developers.google.com/instance-id/reference/server#get_information_about_app_instances
+type TokenInfo struct {
+	// The app the token belongs to, i.e. its package name or bundle ID.
+	Application string
+
+	// Version of the application.
+	ApplicationVersion string
+
+	// Project number of the sender authorized to send to the token.
+	AuthorizedEntity string
+
+	// Platform of the app instance: "ANDROID", "IOS" or "CHROME".
+	Platform string
+
+	// SHA1 fingerprint of the signature applied to the app package.
+	AppSigner string
+
+	// Whether the device is "ROOTED" or "NOT_ROOTED".
+	AttestStatus string
+
+	// Connection type of the device: "WIFI", "MOBILE" or "OTHER".
+	ConnectionType string
+
+	// Date of the device's last connection. The Instance ID service does not
+	// report when a token was created; this is the closest it offers.
+	ConnectDate time.Time
+
+	// Topics the token is subscribed to, keyed by topic name.
+	Topics map[string]TopicSubscription
+}
+
+// TopicSubscription describes the subscription of a registration token to a topic.
+type TopicSubscription struct {
+	// Date the token was subscribed to the topic.
+	AddDate time.Time
+}
+
+type tokenInfoResponse struct {
+	Application        string `json:"application"`
+	ApplicationVersion string `json:"applicationVersion"`
+	AuthorizedEntity   string `json:"authorizedEntity"`
+	Platform           string `json:"platform"`
+	AppSigner          string `json:"appSigner"`
+	AttestStatus       string `json:"attestStatus"`
+	ConnectionType     string `json:"connectionType"`
+	ConnectDate        string `json:"connectDate"`
+	Rel                struct {
+		Topics map[string]struct {
+			AddDate string `json:"addDate"`
+		} `json:"topics"`
+	} `json:"rel"`
+}
+
+// GetTokenInfo returns information about a registration token, including the
+// topics it is subscribed to. An unknown token results in an UNREGISTERED
+// *Error and a malformed token in an INVALID_ARGUMENT *Error.
+func (c *Client) GetTokenInfo(ctx context.Context, token string) (*TokenInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// validate
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	endpoint := fmt.Sprintf("%s/iid/info/%s?details=true", c.iidEndpoint, url.PathEscape(token))
+	resp := new(tokenInfoResponse)
+	err := c.do(ctx, func() (*http.Request, error) {
+		return newIIDRequest(http.MethodGet, endpoint, nil)
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TokenInfo{
+		Application:        resp.Application,
+		ApplicationVersion: resp.ApplicationVersion,
+		AuthorizedEntity:   resp.AuthorizedEntity,
+		Platform:           resp.Platform,
+		AppSigner:          resp.AppSigner,
+		AttestStatus:       resp.AttestStatus,
+		ConnectionType:     resp.ConnectionType,
+		ConnectDate:        parseIIDDate(resp.ConnectDate),
+		Topics:             make(map[string]TopicSubscription, len(resp.Rel.Topics)),
+	}
+
+	for name, topic := range resp.Rel.Topics {
+		info.Topics[name] = TopicSubscription{AddDate: parseIIDDate(topic.AddDate)}
+	}
+
+	return info, nil
+}
+
+// ApnsImportResponse holds the result of importing APNs tokens.
+type ApnsImportResponse struct {
+	// Number of APNs tokens that were imported successfully.
+	SuccessCount int
+
+	// Number of APNs tokens that could not be imported.
+	FailureCount int
+
+	// Results of the individual APNs tokens, in the same order as the input.
+	Results []*ApnsImportResult
+}
+
+// ApnsImportResult is the result of importing a single APNs token.
+type ApnsImportResult struct {
+	// The imported APNs device token.
+	ApnsToken string
+
+	// FCM registration token the APNs token was mapped to.
+	RegistrationToken string
+
+	// Error that occurred while importing the APNs token, if any.
+	Error error
+}
+
+// Success reports whether the APNs token was imported successfully.
+func (r *ApnsImportResult) Success() bool {
+	return r.Error == nil
+}
+
+type apnsImportRequest struct {
+	Application string   `json:"application"`
+	Sandbox     bool     `json:"sandbox"`
+	ApnsTokens  []string `json:"apns_tokens"`
+}
+
+type apnsImportResult struct {
+	Results []struct {
+		ApnsToken         string `json:"apns_token"`
+		Status            string `json:"status"`
+		RegistrationToken string `json:"registration_token,omitempty"`
+	} `json:"results"`
+}
+
+// ImportAPNsTokens maps raw APNs device tokens of the app with the given
+// bundle ID to FCM registration tokens, so that they can be sent to with
+// Send. Set sandbox for tokens of the APNs development environment. Tokens
+// are sent to the Instance ID service in chunks of 100.
+//
+// The returned error is only non-nil if the request is invalid or a chunk
+// could not be sent. Errors of individual APNs tokens are reported in the
+// ApnsImportResponse as *Error. If a chunk cannot be sent, the response is
+// returned along with the error: it holds the results of the earlier chunks,
+// and the tokens of the failed and later chunks fail with that error.
+func (c *Client) ImportAPNsTokens(ctx context.Context, bundleID string, sandbox bool, apnsTokens []string) (*ApnsImportResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// validate
+	if bundleID == "" {
+		return nil, ErrInvalidBundleID
+	}
+
+	if len(apnsTokens) == 0 {
+		return nil, ErrNoTokens
+	}
+
+	for i, token := range apnsTokens {
+		if token == "" {
+			return nil, fmt.Errorf("apns token at index %d is empty", i)
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/iid/v1:batchImport", c.iidEndpoint)
+	response := &ApnsImportResponse{Results: make([]*ApnsImportResult, 0, len(apnsTokens))}
+	for start := 0; start < len(apnsTokens); start += maxApnsImportTokens {
+		end := start + maxApnsImportTokens
+		if end > len(apnsTokens) {
+			end = len(apnsTokens)
+		}
+
+		data, err := json.Marshal(&apnsImportRequest{
+			Application: bundleID,
+			Sandbox:     sandbox,
+			ApnsTokens:  apnsTokens[start:end],
+		})
+		if err != nil {
+			return response.failRemaining(apnsTokens, start, err)
+		}
+
+		result := new(apnsImportResult)
+		err = c.do(ctx, func() (*http.Request, error) {
+			return newIIDRequest(http.MethodPost, endpoint, data)
+		}, result)
+		if err != nil {
+			return response.failRemaining(apnsTokens, start, err)
+		}
+
+		if len(result.Results) != end-start {
+			err := fmt.Errorf("fcm: expected %d apns import results, got %d", end-start, len(result.Results))
+			return response.failRemaining(apnsTokens, start, err)
+		}
+
+		for i, r := range result.Results {
+			ir := &ApnsImportResult{
+				ApnsToken:         apnsTokens[start+i],
+				RegistrationToken: r.RegistrationToken,
+			}
+
+			if r.Status == "OK" && r.RegistrationToken != "" {
+				response.SuccessCount++
+			} else {
+				ir.Error = newApnsImportError(r.Status)
+				response.FailureCount++
+			}
+
+			response.Results = append(response.Results, ir)
+		}
+	}
+
+	return response, nil
+}
+
+// failRemaining reports the APNs tokens from index start on as failed with
+// err, and returns the response along with err.
+func (r *ApnsImportResponse) failRemaining(apnsTokens []string, start int, err error) (*ApnsImportResponse, error) {
+	for _, token := range apnsTokens[start:] {
+		r.FailureCount++
+		r.Results = append(r.Results, &ApnsImportResult{ApnsToken: token, Error: err})
+	}
+
+	return r, err
+}
+
+// newApnsImportError builds the error of an APNs token that could not be
+// imported out of the status reported by the Instance ID service, which is
+// either a canonical status or the text of an HTTP status, e.g. "Internal
+// Server Error".
+func newApnsImportError(status string) *Error {
+	statusCode := 0
+	for code := http.StatusBadRequest; code <= http.StatusNetworkAuthenticationRequired; code++ {
+		if http.StatusText(code) == status {
+			statusCode = code
+			break
+		}
+	}
+
+	return &Error{
+		Code:    errorCodeFromStatus(status, statusCode),
+		Status:  status,
+		Message: fmt.Sprintf("failed to import apns token: %s", status),
+	}
+}
+
+// parseIIDDate parses a date such as "2015-07-30". Malformed dates result in
+// the zero time.
+func parseIIDDate(value string) time.Time {
+	t, _ := time.Parse(iidDateLayout, value)
+	return t
+}
+
+// newIIDRequest creates a request to the Instance ID service, which requires
+// the access_token_auth header to accept OAuth2 access tokens.
+func newIIDRequest(method, url string, data []byte) (*http.Request, error) {
+	req, err := newJSONRequest(method, url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("access_token_auth", "true")
+	return req, nil
+}
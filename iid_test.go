@@ -0,0 +1,149 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetTokenInfo(t *testing.T) {
+	t.Run("details", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/iid/info/abc:def" {
+				t.Errorf("unexpected path: %v", r.URL.Path)
+			}
+			if r.URL.Query().Get("details") != "true" {
+				t.Error("expected details=true")
+			}
+			w.Write([]byte(`{
+				"application": "com.github.gofcm",
+				"applicationVersion": "12",
+				"authorizedEntity": "123456789",
+				"platform": "ANDROID",
+				"connectDate": "2018-05-12",
+				"rel": {"topics": {"cats": {"addDate": "2018-07-30"}}}
+			}`))
+		})
+		defer srv.Close()
+
+		info, err := c.GetTokenInfo(context.Background(), "abc:def")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Application != "com.github.gofcm" || info.Platform != "ANDROID" || info.ApplicationVersion != "12" {
+			t.Fatalf("unexpected info: %+v", info)
+		}
+		if !info.ConnectDate.Equal(time.Date(2018, 5, 12, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected connect date: %v", info.ConnectDate)
+		}
+		if !info.Topics["cats"].AddDate.Equal(time.Date(2018, 7, 30, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected topics: %v", info.Topics)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "No information found about this instance id."}`))
+		})
+		defer srv.Close()
+
+		_, err := c.GetTokenInfo(context.Background(), "abc")
+		if !IsUnregistered(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeUnregistered, err)
+		}
+	})
+}
+
+func TestImportAPNsTokens(t *testing.T) {
+	var chunks []int
+	c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/iid/v1:batchImport" {
+			t.Errorf("unexpected path: %v", r.URL.Path)
+		}
+
+		var req apnsImportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if req.Application != "com.github.gofcm" || !req.Sandbox {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		chunks = append(chunks, len(req.ApnsTokens))
+
+		results := make([]string, len(req.ApnsTokens))
+		for i, token := range req.ApnsTokens {
+			if token == "bad" {
+				results[i] = `{"apns_token": "bad", "status": "Internal Server Error"}`
+			} else {
+				results[i] = fmt.Sprintf(`{"apns_token": %q, "status": "OK", "registration_token": "fcm-%s"}`, token, token)
+			}
+		}
+		w.Write([]byte(`{"results": [` + strings.Join(results, ",") + `]}`))
+	})
+	defer srv.Close()
+
+	tokens := make([]string, 150)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("apns-%d", i)
+	}
+	tokens[120] = "bad"
+
+	resp, err := c.ImportAPNsTokens(context.Background(), "com.github.gofcm", true, tokens)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0] != 100 || chunks[1] != 50 {
+		t.Fatalf("unexpected chunks: %v", chunks)
+	}
+	if resp.SuccessCount != 149 || resp.FailureCount != 1 {
+		t.Fatalf("unexpected counts: %d successes, %d failures", resp.SuccessCount, resp.FailureCount)
+	}
+	if resp.Results[7].RegistrationToken != "fcm-apns-7" {
+		t.Fatalf("expected: %v got: %v", "fcm-apns-7", resp.Results[7].RegistrationToken)
+	}
+	if resp.Results[120].Success() || resp.Results[120].ApnsToken != "bad" {
+		t.Fatalf("unexpected result: %+v", resp.Results[120])
+	}
+	if !IsInternal(resp.Results[120].Error) {
+		t.Fatalf("expected %v, got %v", ErrorCodeInternal, resp.Results[120].Error)
+	}
+
+	t.Run("chunk failure", func(t *testing.T) {
+		var calls int
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			var req apnsImportRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			results := make([]string, len(req.ApnsTokens))
+			for i, token := range req.ApnsTokens {
+				results[i] = fmt.Sprintf(`{"apns_token": %q, "status": "OK", "registration_token": "fcm-%s"}`, token, token)
+			}
+			w.Write([]byte(`{"results": [` + strings.Join(results, ",") + `]}`))
+		})
+		defer srv.Close()
+
+		resp, err := c.ImportAPNsTokens(context.Background(), "com.github.gofcm", true, tokens)
+		if !IsUnavailable(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeUnavailable, err)
+		}
+		if resp == nil || resp.SuccessCount != 100 || resp.FailureCount != 50 || len(resp.Results) != 150 {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		if resp.Results[99].RegistrationToken != "fcm-apns-99" || resp.Results[100].ApnsToken != "apns-100" || !IsUnavailable(resp.Results[100].Error) {
+			t.Fatalf("unexpected results: %+v %+v", resp.Results[99], resp.Results[100])
+		}
+	})
+}
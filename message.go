@@ -1,10 +1,12 @@
 package fcm
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
-	"time"
 )
 
 var (
@@ -17,10 +19,43 @@ var (
 	// ErrInvalidTimeToLive occurs if TimeToLive more then 2419200.
 	ErrInvalidTimeToLive = errors.New("messages time-to-live is invalid")
 
-	// ErrInvalidApnsPriority occurs if the priority is not 5 or 10.
+	// ErrInvalidApnsPriority occurs if the priority is not 1, 5 or 10, or is 10
+	// for a notification that only sets content-available.
 	ErrInvalidApnsPriority = errors.New("apns message priority is invalid")
+
+	// ErrInvalidAnalyticsLabel occurs if an analytics label is longer than 50
+	// characters or uses characters other than [a-zA-Z0-9-_.~%].
+	ErrInvalidAnalyticsLabel = errors.New("analytics label is invalid")
+
+	// ErrInvalidImage occurs if the notification image is not an HTTPS URL.
+	ErrInvalidImage = errors.New("notification image must be an https url")
+
+	// ErrReservedDataKey occurs if a data map uses a key reserved by FCM.
+	ErrReservedDataKey = errors.New("data key is reserved")
+
+	// ErrInvalidLocArgs occurs if localization args are set without the matching localization key.
+	ErrInvalidLocArgs = errors.New("localization args are set without a localization key")
+)
+
+// data keys reserved by FCM, and prefixes of reserved keys
+var (
+	reservedDataKeys        = []string{"from", "notification", "message_type"}
+	reservedDataKeyPrefixes = []string{"google.", "gcm."}
+)
+
+var (
+	analyticsLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9-_.~%]{1,50}$`)
+
+	// characters of FCM registration tokens
+	registrationTokenPattern = regexp.MustCompile(`^[a-zA-Z0-9-_:]+$`)
+
+	// APNs device tokens are 32 bytes, usually written in hex
+	apnsTokenPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
 )
 
+// prefix of GCM registration ids issued before FCM tokens
+const legacyRegistrationIDPrefix = "APA91b"
+
 // SendRequest has a flag for testing and the actual message to send.
 type SendRequest struct {
 	// Flag for testing the request without actually delivering the message.
@@ -36,6 +71,17 @@ type Notification struct {
 
 	// The notification's body text.
 	Body string `json:"body,omitempty"`
+
+	// Contains the URL of an image that is going to be downloaded on the
+	// device and displayed in a notification. Must be an HTTPS URL.
+	Image string `json:"image,omitempty"`
+}
+
+// FcmOptions holds platform independent options for features provided by the FCM SDKs.
+type FcmOptions struct {
+	// Label associated with the message's analytics data, e.g. for BigQuery
+	// export and delivery reports. At most 50 characters from [a-zA-Z0-9-_.~%].
+	AnalyticsLabel string `json:"analytics_label,omitempty"`
 }
 
 // Message represents list of targets, options, and payload for HTTP JSON
@@ -69,6 +115,9 @@ type Message struct {
 	// An object containing a list of "key": value pairs.
 	// Example: { "name": "wrench", "mass": "1.3kg", "count": "3" }.
 	Data map[string]string `json:"data,omitempty"`
+
+	// Template for FCM SDK feature options to use across all platforms.
+	FcmOptions *FcmOptions `json:"fcm_options,omitempty"`
 }
 
 // MessageID returns the message id the successful send request.
@@ -81,12 +130,23 @@ func (msg Message) MessageID() string {
 	return ""
 }
 
-// Validate returns an error if the message is not well-formed.
+// Validate returns an error if the message is not well-formed. The error is
+// a *ValidationError holding every problem found in the message.
 func (msg *Message) Validate() error {
+	v := &validator{}
 	if msg == nil {
-		return ErrInvalidMessage
+		v.add("message", ErrInvalidMessage)
+		return v.err()
 	}
 
+	msg.validateTarget(v)
+	msg.validatePayload(v)
+
+	return v.err()
+}
+
+// validateTarget checks that exactly one of `topic`, `condition` or `token` is set.
+func (msg *Message) validateTarget(v *validator) {
 	var targets = 0
 	// validate target: `topic` or `condition`, or `token`
 	if msg.Topic != "" {
@@ -97,44 +157,103 @@ func (msg *Message) Validate() error {
 		targets = targets + 1
 	}
 
-	opCnt := strings.Count(msg.Condition, "&&") + strings.Count(msg.Condition, "||")
-	if opCnt > 2 {
-		return ErrInvalidTarget
-	}
-
 	if msg.Token != "" {
 		targets = targets + 1
 	}
 
 	if targets == 0 || targets > 1 {
-		return ErrInvalidTarget
+		v.add("message", ErrInvalidTarget)
+		return
 	}
 
-	if msg.Android != nil && msg.Android.TTL != "" {
-		if _, err := time.ParseDuration(msg.Android.TTL); err != nil {
-			return ErrInvalidTimeToLive
-		}
+	if msg.Topic != "" {
+		v.add("message.topic", validateTopicName(msg.Topic))
 	}
 
-	if msg.Apns != nil {
-		b, err := json.Marshal(msg.Apns.Payload)
-		if err != nil {
-			return err
-		}
+	if msg.Token != "" {
+		v.add("message.token", validateToken(msg.Token))
+	}
+
+	if msg.Condition != "" {
+		_, err := ParseCondition(msg.Condition)
+		v.add("message.condition", err)
+	}
+}
+
+// validateToken checks that token looks like an FCM registration token.
+func validateToken(token string) error {
+	if apnsTokenPattern.MatchString(token) {
+		return fmt.Errorf("%w: '%s' looks like an APNs device token, convert it with ImportAPNsTokens", ErrInvalidToken, token)
+	}
+
+	if strings.HasPrefix(token, legacyRegistrationIDPrefix) && !strings.Contains(token, ":") {
+		return fmt.Errorf("%w: '%s' looks like a legacy GCM registration id", ErrInvalidToken, token)
+	}
+
+	if !registrationTokenPattern.MatchString(token) {
+		return fmt.Errorf("%w: '%s' must match [a-zA-Z0-9-_:]+", ErrInvalidToken, token)
+	}
+
+	return nil
+}
+
+// validateData checks that the data map at path uses no reserved keys.
+func validateData(v *validator, path string, data map[string]string) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-		var payload ApnsPayload
-		err = json.Unmarshal(b, &payload)
-		if err != nil {
-			return err
+	for _, k := range keys {
+		for _, reserved := range reservedDataKeys {
+			if k == reserved {
+				v.add(path+"."+k, fmt.Errorf("%w: '%s' in %s", ErrReservedDataKey, k, path))
+			}
 		}
 
-		if msg.Apns.Headers != nil {
-			if payload.Aps.ContentAvailable == int(ApnsContentAvailable) &&
-				msg.Apns.Headers.Priority == string(ApnsHighPriority) {
-				return ErrInvalidApnsPriority
+		for _, prefix := range reservedDataKeyPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				v.add(path+"."+k, fmt.Errorf("%w: '%s' in %s, keys must not start with '%s'", ErrReservedDataKey, k, path, prefix))
 			}
 		}
 	}
+}
 
-	return nil
+// validatePayload checks everything but the target of the message.
+func (msg *Message) validatePayload(v *validator) {
+	validateData(v, "message.data", msg.Data)
+
+	if msg.Notification != nil && msg.Notification.Image != "" && !isHTTPSURL(msg.Notification.Image) {
+		v.add("message.notification.image", ErrInvalidImage)
+	}
+
+	if msg.FcmOptions != nil && !validAnalyticsLabel(msg.FcmOptions.AnalyticsLabel) {
+		v.add("message.fcm_options.analytics_label", ErrInvalidAnalyticsLabel)
+	}
+
+	if msg.Android != nil {
+		msg.Android.validate(v, "message.android")
+	}
+
+	if msg.Webpush != nil {
+		msg.Webpush.validate(v, "message.webpush")
+	}
+
+	if msg.Apns != nil {
+		msg.Apns.validate(v, "message.apns", msg.Notification)
+	}
+
+	msg.validateSize(v)
+}
+
+// validAnalyticsLabel reports whether label is empty or a valid analytics label.
+func validAnalyticsLabel(label string) bool {
+	return label == "" || analyticsLabelPattern.MatchString(label)
+}
+
+// isHTTPSURL reports whether s is an absolute HTTPS URL.
+func isHTTPSURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "https" && u.Host != ""
 }
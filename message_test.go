@@ -1,6 +1,8 @@
 package fcm
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -92,7 +94,7 @@ func TestValidate(t *testing.T) {
 
 	t.Run("invalid condition", func(t *testing.T) {
 		msg := &Message{
-			Condition: "'TopicA' in topics && ('TopicB' in topics || 'TopicC' in topics || 'TopicD' in topics )",
+			Condition: "'TopicA' in topics && ('TopicB' in topics || 'TopicC' in topics || 'TopicD' in topics || 'TopicE' in topics || 'TopicF' in topics)",
 		}
 		err := msg.Validate()
 		if err == nil {
@@ -161,3 +163,86 @@ func TestValidate(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateFcmOptionsAndImage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+		err  error
+	}{
+		{"valid label", &Message{Topic: "test", FcmOptions: &FcmOptions{AnalyticsLabel: "spring_sale-2020.v1~%20"}}, nil},
+		{"label with space", &Message{Topic: "test", FcmOptions: &FcmOptions{AnalyticsLabel: "spring sale"}}, ErrInvalidAnalyticsLabel},
+		{"label too long", &Message{Topic: "test", FcmOptions: &FcmOptions{AnalyticsLabel: strings.Repeat("a", 51)}}, ErrInvalidAnalyticsLabel},
+		{"invalid android label", &Message{Topic: "test", Android: &AndroidConfig{FcmOptions: &AndroidFcmOptions{AnalyticsLabel: "a/b"}}}, ErrInvalidAnalyticsLabel},
+		{"invalid webpush label", &Message{Topic: "test", Webpush: &WebpushConfig{FcmOptions: &WebpushFcmOptions{AnalyticsLabel: "a/b"}}}, ErrInvalidAnalyticsLabel},
+		{"https image", &Message{Topic: "test", Notification: &Notification{Image: "https://example.com/a.png"}}, nil},
+		{"http image", &Message{Topic: "test", Notification: &Notification{Image: "http://example.com/a.png"}}, ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateTargetFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+		err  error
+	}{
+		{"topic", &Message{Topic: "news-2020_v1.a~b%20"}, nil},
+		{"topic with prefix", &Message{Topic: "/topics/news"}, ErrInvalidTopic},
+		{"topic with space", &Message{Topic: "breaking news"}, ErrInvalidTopic},
+		{"token", &Message{Token: "bk3RNwTe3H0:CI2k_HHwgIpoDKCIZvvDMExUdFQ3P1-abc"}, nil},
+		{"apns token", &Message{Token: strings.Repeat("0a", 32)}, ErrInvalidToken},
+		{"legacy registration id", &Message{Token: "APA91bHun4MxP5egoKMwt2KZFBaFUH"}, ErrInvalidToken},
+		{"token with space", &Message{Token: "abc def"}, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateReservedDataKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+		err  string
+	}{
+		{"from", &Message{Topic: "test", Data: map[string]string{"from": "x"}}, "'from' in message.data"},
+		{"notification", &Message{Topic: "test", Data: map[string]string{"a": "x", "notification": "x"}}, "'notification' in message.data"},
+		{"google prefix", &Message{Topic: "test", Android: &AndroidConfig{Data: map[string]string{"google.sent_time": "x"}}}, "'google.sent_time' in message.android.data"},
+		{"gcm prefix", &Message{Topic: "test", Webpush: &WebpushConfig{Data: map[string]string{"gcm.n.e": "x"}}}, "'gcm.n.e' in message.webpush.data"},
+		{"message type", &Message{Topic: "test", Android: &AndroidConfig{Data: map[string]string{"message_type": "x"}}}, "'message_type' in message.android.data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if !errors.Is(err, ErrReservedDataKey) {
+				t.Fatalf("expected: %v got: %v", ErrReservedDataKey, err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error to contain %v, got: %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("not reserved", func(t *testing.T) {
+		msg := &Message{Topic: "test", Data: map[string]string{"fromage": "brie", "googled": "yes"}}
+		if err := msg.Validate(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
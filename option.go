@@ -1,13 +1,111 @@
 package fcm
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
 )
 
 // Option configurates Client with defined option.
 type Option func(*Client) error
 
+// WithProjectID returns Option to configure the Firebase project id. If it is
+// not set, the project id is detected from the credentials.
+func WithProjectID(projectID string) Option {
+	return func(c *Client) error {
+		if projectID == "" {
+			return errors.New("invalid project id")
+		}
+		c.projectID = projectID
+		return nil
+	}
+}
+
+// WithCredentialsFile returns Option to authenticate with a service account
+// key, authorized user or external account JSON file.
+func WithCredentialsFile(filename string) Option {
+	return func(c *Client) error {
+		if filename == "" {
+			return errors.New("invalid credentials file")
+		}
+		c.credentials = func(ctx context.Context, c *Client) (*credentials, error) {
+			return credentialsFromFile(ctx, c, filename)
+		}
+		return nil
+	}
+}
+
+// WithCredentialsJSON returns Option to authenticate with the contents of a
+// service account key, authorized user or external account JSON file, e.g.
+// as loaded from a secret manager or an environment variable.
+func WithCredentialsJSON(jsonKey []byte) Option {
+	return func(c *Client) error {
+		if len(jsonKey) == 0 {
+			return errors.New("invalid credentials json")
+		}
+		c.credentials = func(ctx context.Context, c *Client) (*credentials, error) {
+			return credentialsFromJSON(ctx, c, jsonKey)
+		}
+		return nil
+	}
+}
+
+// WithTokenSource returns Option to authenticate with tokens from ts. The
+// tokens must be valid for the firebase.messaging scope. The project id
+// cannot be detected from a token source and must be configured with
+// WithProjectID or the GOOGLE_CLOUD_PROJECT environment variable.
+func WithTokenSource(ts oauth2.TokenSource) Option {
+	return func(c *Client) error {
+		if ts == nil {
+			return errors.New("invalid token source")
+		}
+		c.credentials = func(ctx context.Context, c *Client) (*credentials, error) {
+			return &credentials{tokenSource: staticTokenSourceFunc(ts)}, nil
+		}
+		return nil
+	}
+}
+
+// WithDefaultCredentials returns Option to authenticate with Application
+// Default Credentials: the file named by the GOOGLE_APPLICATION_CREDENTIALS
+// environment variable, the well known file written by
+// `gcloud auth application-default login`, or else the metadata server.
+// This is the default if no other credentials are configured.
+func WithDefaultCredentials() Option {
+	return func(c *Client) error {
+		c.credentials = defaultCredentials
+		return nil
+	}
+}
+
+// WithMetadataCredentials returns Option to authenticate with the default
+// service account of the GCE metadata server, as available on GCE, GKE
+// (including workload identity), Cloud Run and Cloud Functions. The project
+// id is detected from the metadata server. The GCE_METADATA_HOST environment
+// variable overrides the host, and optionally port, of the metadata server.
+func WithMetadataCredentials() Option {
+	return func(c *Client) error {
+		c.credentials = metadataCredentials
+		return nil
+	}
+}
+
+// WithSTSEndpoint returns Option to configure the Security Token Service
+// endpoint that external account credentials exchange their subject token
+// at. It overrides the token_url of the credentials.
+func WithSTSEndpoint(endpoint string) Option {
+	return func(c *Client) error {
+		if endpoint == "" {
+			return errors.New("invalid endpoint")
+		}
+		c.stsEndpoint = endpoint
+		return nil
+	}
+}
+
 // WithEndpoint returns Option to configure FCM Endpoint.
 func WithEndpoint(endpoint string) Option {
 	return func(c *Client) error {
@@ -19,6 +117,18 @@ func WithEndpoint(endpoint string) Option {
 	}
 }
 
+// WithIIDEndpoint returns Option to configure the base URL of the Instance ID
+// service used for topic management, e.g. "https://iid.googleapis.com".
+func WithIIDEndpoint(endpoint string) Option {
+	return func(c *Client) error {
+		if endpoint == "" {
+			return errors.New("invalid endpoint")
+		}
+		c.iidEndpoint = strings.TrimSuffix(endpoint, "/")
+		return nil
+	}
+}
+
 // WithHTTPClient returns Option to configure HTTP Client.
 func WithHTTPClient(httpClient *http.Client) Option {
 	return func(c *Client) error {
@@ -26,3 +136,50 @@ func WithHTTPClient(httpClient *http.Client) Option {
 		return nil
 	}
 }
+
+// WithRetryPolicy returns Option to retry failed requests according to policy.
+// Requests are not retried if no policy is configured.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *Client) error {
+		if policy == nil {
+			return errors.New("invalid retry policy")
+		}
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+// WithMaxConcurrency returns Option to configure how many requests batch
+// operations such as SendMulticast and SendAll send concurrently.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Client) error {
+		if n < 1 {
+			return errors.New("invalid max concurrency")
+		}
+		c.maxConcurrency = n
+		return nil
+	}
+}
+
+// WithApnsHeaderInference returns Option to infer the apns-push-type and
+// apns-priority headers of APNs messages that don't set them, from the topic
+// header and the aps dictionary. For example, a payload that only sets
+// content-available is sent as a background push with priority 5.
+func WithApnsHeaderInference() Option {
+	return func(c *Client) error {
+		c.inferApnsHeaders = true
+		return nil
+	}
+}
+
+// WithTopicNormalization returns Option to strip the "/topics/" prefix of
+// message topics, as used by the legacy HTTP API, instead of rejecting it.
+func WithTopicNormalization() Option {
+	return func(c *Client) error {
+		c.normalizeTopics = true
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// RetryPolicy configures how a Client retries requests that failed with a
+// transient transport error, a 5xx or 429 response, or an UNAVAILABLE error. Requests
+// that failed with a non-retryable error, e.g. INVALID_ARGUMENT, are never retried.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts int
+
+	// Delay before the first retry.
+	InitialBackoff time.Duration
+
+	// Upper bound of the exponential backoff. A Retry-After header sent by
+	// the server takes precedence over this value.
+	MaxBackoff time.Duration
+
+	// Factor the backoff is multiplied by after each attempt.
+	Multiplier float64
+
+	// Fraction of the backoff, between 0 and 1, that is randomized so that
+	// clients do not retry in lockstep.
+	Jitter float64
+
+	// Overall time budget for a request including all retries and the
+	// waits between them. A retry is not attempted if it would start after
+	// the budget is spent. Zero means no limit.
+	Budget time.Duration
+}
+
+// DefaultRetryPolicy returns a RetryPolicy that makes up to 5 attempts within one minute.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Budget:         time.Minute,
+	}
+}
+
+// RetryError is returned by a Client configured with a RetryPolicy when a
+// request did not succeed. It reports the number of attempts made and wraps
+// the error of the last attempt, or the error of the context if it was done
+// before the request succeeded.
+type RetryError struct {
+	// Number of attempts made.
+	Attempts int
+
+	// Error of the last attempt, or of the context.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.New("retry policy must allow at least one attempt")
+	}
+
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 || p.Budget < 0 {
+		return errors.New("retry policy durations must not be negative")
+	}
+
+	if p.Multiplier < 1 {
+		return errors.New("retry policy multiplier must be at least 1")
+	}
+
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("retry policy jitter must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// do calls fn until it succeeds, returns a non-retryable error, or the
+// policy gives up.
+func (p *RetryPolicy) do(ctx context.Context, fn func() error) error {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return &RetryError{Attempts: attempt, Err: ctx.Err()}
+		}
+
+		if attempt >= p.MaxAttempts || !shouldRetry(ctx, err) {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		wait := p.backoff(attempt, err)
+		if p.Budget > 0 && time.Since(start)+wait > p.Budget {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &RetryError{Attempts: attempt, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns how long to wait after the given attempt failed with err.
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	// spread the delay evenly over [d - jitter*d, d + jitter*d]
+	d += d * p.Jitter * (2*rand.Float64() - 1)
+	wait := time.Duration(d)
+
+	var e *Error
+	if errors.As(err, &e) && e.RetryAfter > wait {
+		wait = e.RetryAfter
+	}
+
+	return wait
+}
+
+// shouldRetry reports whether a request that failed with err may be sent
+// again. Nothing is retried once ctx is done.
+func shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return IsRetryable(e)
+	}
+
+	// the token endpoint may also be temporarily unavailable
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) {
+		return re.Response != nil && re.Response.StatusCode >= http.StatusInternalServerError
+	}
+
+	// transport errors are returned by http.Client wrapped in a *url.Error.
+	// Of those only timeouts and temporary network errors, e.g. a connection
+	// reset, are retried; TLS failures and malformed URLs are not.
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return false
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(ue.Err, &timeout) && timeout.Timeout() {
+		return true
+	}
+
+	if errors.Is(ue.Err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(ue.Err, &temporary) && temporary.Temporary()
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,222 @@
+package fcm
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	sendRequest := &SendRequest{Message: &Message{Topic: "cats"}}
+
+	t.Run("retries unavailable", func(t *testing.T) {
+		var calls int32
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+		c.retryPolicy = testRetryPolicy()
+
+		if _, err := c.SendContext(context.Background(), sendRequest); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Fatalf("expected: %v got: %v", 3, calls)
+		}
+	})
+
+	t.Run("reports attempts", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		defer srv.Close()
+		c.retryPolicy = testRetryPolicy()
+
+		_, err := c.SendContext(context.Background(), sendRequest)
+
+		var re *RetryError
+		if !errors.As(err, &re) {
+			t.Fatalf("expected *RetryError, got %T", err)
+		}
+		if re.Attempts != 3 {
+			t.Fatalf("expected: %v got: %v", 3, re.Attempts)
+		}
+		if !IsInternal(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeInternal, err)
+		}
+	})
+
+	t.Run("does not retry invalid argument", func(t *testing.T) {
+		var calls int32
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"code": 400, "status": "INVALID_ARGUMENT"}}`))
+		})
+		defer srv.Close()
+		c.retryPolicy = testRetryPolicy()
+
+		_, err := c.SendContext(context.Background(), sendRequest)
+		if !IsInvalidArgument(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeInvalidArgument, err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected: %v got: %v", 1, calls)
+		}
+	})
+
+	t.Run("budget exhausted", func(t *testing.T) {
+		var calls int32
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		defer srv.Close()
+		c.retryPolicy = testRetryPolicy()
+		c.retryPolicy.Budget = time.Second
+
+		_, err := c.SendContext(context.Background(), sendRequest)
+		if !IsQuotaExceeded(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeQuotaExceeded, err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected: %v got: %v", 1, calls)
+		}
+	})
+
+	t.Run("canceled between attempts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var calls int32
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		defer srv.Close()
+		c.retryPolicy = testRetryPolicy()
+		c.retryPolicy.InitialBackoff = time.Minute
+		c.retryPolicy.MaxBackoff = time.Minute
+
+		_, err := c.SendContext(ctx, sendRequest)
+		var re *RetryError
+		if !errors.As(err, &re) {
+			t.Fatalf("expected *RetryError, got %T", err)
+		}
+		if re.Attempts != 1 {
+			t.Fatalf("expected: %v got: %v", 1, re.Attempts)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected: %v got: %v", context.Canceled, err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected: %v got: %v", 1, calls)
+		}
+	})
+
+	t.Run("deadline between attempts", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		defer srv.Close()
+		c.retryPolicy = testRetryPolicy()
+		c.retryPolicy.InitialBackoff = time.Minute
+		c.retryPolicy.MaxBackoff = time.Minute
+
+		_, err := c.SendContext(ctx, sendRequest)
+		var re *RetryError
+		if !errors.As(err, &re) {
+			t.Fatalf("expected *RetryError, got %T", err)
+		}
+		if re.Attempts != 1 {
+			t.Fatalf("expected: %v got: %v", 1, re.Attempts)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected: %v got: %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("honors retry after", func(t *testing.T) {
+		p := testRetryPolicy()
+		wait := p.backoff(1, &Error{StatusCode: http.StatusServiceUnavailable, RetryAfter: 3 * time.Second})
+		if wait != 3*time.Second {
+			t.Fatalf("expected: %v got: %v", 3*time.Second, wait)
+		}
+	})
+
+	t.Run("caps backoff", func(t *testing.T) {
+		p := testRetryPolicy()
+		p.Jitter = 0.5
+		for attempt := 1; attempt < 10; attempt++ {
+			if wait := p.backoff(attempt, nil); wait > 15*time.Millisecond {
+				t.Fatalf("attempt %d: backoff %v exceeds jittered max", attempt, wait)
+			}
+		}
+	})
+
+	t.Run("transport errors", func(t *testing.T) {
+		urlError := func(err error) error {
+			return &url.Error{Op: "Post", URL: "https://fcm.googleapis.com", Err: err}
+		}
+
+		tests := []struct {
+			name     string
+			err      error
+			expected bool
+		}{
+			{"connection reset", urlError(&net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+			{"timeout", urlError(&net.DNSError{IsTimeout: true}), true},
+			{"connection refused", urlError(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), false},
+			{"unknown authority", urlError(x509.UnknownAuthorityError{}), false},
+			{"unsupported scheme", urlError(errors.New("unsupported protocol scheme")), false},
+			{"canceled", urlError(context.Canceled), false},
+			{"deadline exceeded", urlError(context.DeadlineExceeded), false},
+		}
+
+		for _, tt := range tests {
+			if got := shouldRetry(context.Background(), tt.err); got != tt.expected {
+				t.Fatalf("%s: expected: %v got: %v", tt.name, tt.expected, got)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if shouldRetry(ctx, &Error{StatusCode: http.StatusServiceUnavailable}) {
+			t.Fatal("expected no retry once the context is done")
+		}
+	})
+
+	t.Run("parses retry after", func(t *testing.T) {
+		if d := parseRetryAfter("120"); d != 2*time.Minute {
+			t.Fatalf("expected: %v got: %v", 2*time.Minute, d)
+		}
+		if d := parseRetryAfter("soon"); d != 0 {
+			t.Fatalf("expected: %v got: %v", 0, d)
+		}
+	})
+}
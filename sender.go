@@ -0,0 +1,274 @@
+package fcm
+
+import (
+	"context"
+	"time"
+)
+
+// Sender sends messages and manages topic subscriptions. *Client implements
+// it; applications can depend on Sender to substitute a fake or to wrap a
+// client with decorators such as Logging, Metrics and DryRun.
+type Sender interface {
+	SendContext(ctx context.Context, req *SendRequest) (*Message, error)
+	SendMulticast(ctx context.Context, template *Message, tokens []string) (*BatchResponse, error)
+	SendAll(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error)
+	SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error)
+	UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error)
+}
+
+var _ Sender = (*Client)(nil)
+
+// Decorator wraps a Sender to add behavior to it.
+type Decorator func(Sender) Sender
+
+// Decorate wraps s with the decorators. The first decorator is the
+// outermost, i.e. it sees each call first.
+func Decorate(s Sender, decorators ...Decorator) Sender {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		s = decorators[i](s)
+	}
+
+	return s
+}
+
+// Logger is the logging interface used by the Logging decorator. It is
+// satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Logging returns a Decorator that logs every call, its duration and its
+// error, if any.
+func Logging(logger Logger) Decorator {
+	return func(s Sender) Sender {
+		return &loggingSender{next: s, logger: logger}
+	}
+}
+
+type loggingSender struct {
+	next   Sender
+	logger Logger
+}
+
+func (s *loggingSender) log(method string, start time.Time, err error) {
+	if err != nil {
+		s.logger.Printf("fcm: %s failed after %v: %v", method, time.Since(start), err)
+		return
+	}
+
+	s.logger.Printf("fcm: %s succeeded after %v", method, time.Since(start))
+}
+
+func (s *loggingSender) logBatch(method string, start time.Time, resp *BatchResponse, err error) {
+	if err != nil {
+		s.log(method, start, err)
+		return
+	}
+
+	s.logger.Printf("fcm: %s sent %d, failed %d after %v", method, resp.SuccessCount, resp.FailureCount, time.Since(start))
+}
+
+func (s *loggingSender) logTopic(method string, start time.Time, resp *TopicManagementResponse, err error) {
+	if err != nil && resp != nil {
+		s.logger.Printf("fcm: %s succeeded for %d, failed for %d after %v: %v", method, resp.SuccessCount, resp.FailureCount, time.Since(start), err)
+		return
+	}
+
+	if err != nil {
+		s.log(method, start, err)
+		return
+	}
+
+	s.logger.Printf("fcm: %s succeeded for %d, failed for %d after %v", method, resp.SuccessCount, resp.FailureCount, time.Since(start))
+}
+
+func (s *loggingSender) SendContext(ctx context.Context, req *SendRequest) (*Message, error) {
+	start := time.Now()
+	msg, err := s.next.SendContext(ctx, req)
+	s.log("SendContext", start, err)
+	return msg, err
+}
+
+func (s *loggingSender) SendMulticast(ctx context.Context, template *Message, tokens []string) (*BatchResponse, error) {
+	start := time.Now()
+	resp, err := s.next.SendMulticast(ctx, template, tokens)
+	s.logBatch("SendMulticast", start, resp, err)
+	return resp, err
+}
+
+func (s *loggingSender) SendAll(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error) {
+	start := time.Now()
+	resp, err := s.next.SendAll(ctx, reqs)
+	s.logBatch("SendAll", start, resp, err)
+	return resp, err
+}
+
+func (s *loggingSender) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	start := time.Now()
+	resp, err := s.next.SubscribeToTopic(ctx, tokens, topic)
+	s.logTopic("SubscribeToTopic", start, resp, err)
+	return resp, err
+}
+
+func (s *loggingSender) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	start := time.Now()
+	resp, err := s.next.UnsubscribeFromTopic(ctx, tokens, topic)
+	s.logTopic("UnsubscribeFromTopic", start, resp, err)
+	return resp, err
+}
+
+// MetricsRecorder receives the measurements of the Metrics decorator.
+type MetricsRecorder interface {
+	// RecordCall is called once per call of a Sender method, e.g.
+	// "SendMulticast". success and failure count the messages or tokens that
+	// succeeded and failed; err is the error returned by the call, if any.
+	RecordCall(method string, duration time.Duration, success, failure int, err error)
+}
+
+// Metrics returns a Decorator that reports every call to recorder.
+func Metrics(recorder MetricsRecorder) Decorator {
+	return func(s Sender) Sender {
+		return &metricsSender{next: s, recorder: recorder}
+	}
+}
+
+type metricsSender struct {
+	next     Sender
+	recorder MetricsRecorder
+}
+
+func (s *metricsSender) SendContext(ctx context.Context, req *SendRequest) (*Message, error) {
+	start := time.Now()
+	msg, err := s.next.SendContext(ctx, req)
+
+	if err != nil {
+		s.recorder.RecordCall("SendContext", time.Since(start), 0, 1, err)
+	} else {
+		s.recorder.RecordCall("SendContext", time.Since(start), 1, 0, nil)
+	}
+
+	return msg, err
+}
+
+func (s *metricsSender) recordBatch(method string, start time.Time, resp *BatchResponse, err error) {
+	if err != nil {
+		s.recorder.RecordCall(method, time.Since(start), 0, 0, err)
+		return
+	}
+
+	s.recorder.RecordCall(method, time.Since(start), resp.SuccessCount, resp.FailureCount, nil)
+}
+
+func (s *metricsSender) recordTopic(method string, start time.Time, resp *TopicManagementResponse, err error) {
+	if resp == nil {
+		s.recorder.RecordCall(method, time.Since(start), 0, 0, err)
+		return
+	}
+
+	// a chunk failure returns the partial response along with the error
+	s.recorder.RecordCall(method, time.Since(start), resp.SuccessCount, resp.FailureCount, err)
+}
+
+func (s *metricsSender) SendMulticast(ctx context.Context, template *Message, tokens []string) (*BatchResponse, error) {
+	start := time.Now()
+	resp, err := s.next.SendMulticast(ctx, template, tokens)
+	s.recordBatch("SendMulticast", start, resp, err)
+	return resp, err
+}
+
+func (s *metricsSender) SendAll(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error) {
+	start := time.Now()
+	resp, err := s.next.SendAll(ctx, reqs)
+	s.recordBatch("SendAll", start, resp, err)
+	return resp, err
+}
+
+func (s *metricsSender) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	start := time.Now()
+	resp, err := s.next.SubscribeToTopic(ctx, tokens, topic)
+	s.recordTopic("SubscribeToTopic", start, resp, err)
+	return resp, err
+}
+
+func (s *metricsSender) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	start := time.Now()
+	resp, err := s.next.UnsubscribeFromTopic(ctx, tokens, topic)
+	s.recordTopic("UnsubscribeFromTopic", start, resp, err)
+	return resp, err
+}
+
+// DryRun returns a Decorator that sends every message with validate_only
+// set, so that FCM validates it without delivering it. Multicast messages
+// are forwarded to SendAll as a batch of validate-only requests, one per
+// token, so decorators applied after DryRun see a SendAll call. Topic
+// management calls are validated locally and report every token as
+// successful without changing any subscription.
+func DryRun() Decorator {
+	return func(s Sender) Sender {
+		return &dryRunSender{next: s}
+	}
+}
+
+type dryRunSender struct {
+	next Sender
+}
+
+func (s *dryRunSender) SendContext(ctx context.Context, req *SendRequest) (*Message, error) {
+	if req == nil {
+		return nil, ErrInvalidMessage
+	}
+
+	dry := *req
+	dry.ValidateOnly = true
+	return s.next.SendContext(ctx, &dry)
+}
+
+func (s *dryRunSender) SendMulticast(ctx context.Context, template *Message, tokens []string) (*BatchResponse, error) {
+	if err := validateMulticast(template, tokens); err != nil {
+		return nil, err
+	}
+
+	reqs := make([]*SendRequest, len(tokens))
+	for i, token := range tokens {
+		msg := *template
+		msg.Token = token
+		reqs[i] = &SendRequest{ValidateOnly: true, Message: &msg}
+	}
+
+	return s.next.SendAll(ctx, reqs)
+}
+
+func (s *dryRunSender) SendAll(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error) {
+	dry := make([]*SendRequest, len(reqs))
+	for i, req := range reqs {
+		if req == nil {
+			continue
+		}
+
+		r := *req
+		r.ValidateOnly = true
+		dry[i] = &r
+	}
+
+	return s.next.SendAll(ctx, dry)
+}
+
+func (s *dryRunSender) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	return s.manageTopic(ctx, tokens, topic)
+}
+
+func (s *dryRunSender) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	return s.manageTopic(ctx, tokens, topic)
+}
+
+func (s *dryRunSender) manageTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := validateTopicManagement(tokens, topic); err != nil {
+		return nil, err
+	}
+
+	return &TopicManagementResponse{SuccessCount: len(tokens)}, nil
+}
@@ -0,0 +1,214 @@
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedCall struct {
+	method           string
+	success, failure int
+	err              error
+}
+
+type testRecorder struct {
+	mu    sync.Mutex
+	calls []recordedCall
+}
+
+func (r *testRecorder) RecordCall(method string, duration time.Duration, success, failure int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, recordedCall{method, success, failure, err})
+}
+
+// testSender is a Sender other than *Client that records the requests it is
+// asked to send.
+type testSender struct {
+	mu         sync.Mutex
+	reqs       []*SendRequest
+	multicasts int
+}
+
+func (s *testSender) SendContext(ctx context.Context, req *SendRequest) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reqs = append(s.reqs, req)
+	return req.Message, nil
+}
+
+func (s *testSender) SendMulticast(ctx context.Context, template *Message, tokens []string) (*BatchResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.multicasts++
+	return &BatchResponse{SuccessCount: len(tokens)}, nil
+}
+
+func (s *testSender) SendAll(ctx context.Context, reqs []*SendRequest) (*BatchResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reqs = append(s.reqs, reqs...)
+
+	responses := make([]*SendResponse, len(reqs))
+	for i, req := range reqs {
+		responses[i] = &SendResponse{Token: req.Message.Token}
+	}
+	return newBatchResponse(responses), nil
+}
+
+func (s *testSender) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	return &TopicManagementResponse{SuccessCount: len(tokens)}, nil
+}
+
+func (s *testSender) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	return &TopicManagementResponse{SuccessCount: len(tokens)}, nil
+}
+
+func TestDecorators(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		var mu sync.Mutex
+		var validateOnly []bool
+
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			var req SendRequest
+			json.NewDecoder(r.Body).Decode(&req)
+
+			mu.Lock()
+			validateOnly = append(validateOnly, req.ValidateOnly)
+			mu.Unlock()
+
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+
+		recorder := &testRecorder{}
+		s := Decorate(c, DryRun(), Metrics(recorder))
+
+		if _, err := s.SendContext(context.Background(), &SendRequest{Message: &Message{Topic: "cats"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resp, err := s.SendMulticast(context.Background(), &Message{}, []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.SuccessCount != 2 || resp.Responses[1].Token != "b" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		if got := recorder.calls[1].method; got != "SendAll" {
+			t.Fatalf("expected: %v got: %v", "SendAll", got)
+		}
+
+		if len(validateOnly) != 3 {
+			t.Fatalf("expected: %v got: %v", 3, len(validateOnly))
+		}
+		for _, v := range validateOnly {
+			if !v {
+				t.Fatal("expected validate_only to be set")
+			}
+		}
+
+		topicResp, err := s.SubscribeToTopic(context.Background(), []string{"a", "b"}, "cats")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if topicResp.SuccessCount != 2 || len(validateOnly) != 3 {
+			t.Fatalf("unexpected response: %+v", topicResp)
+		}
+
+		if _, err := s.SendMulticast(context.Background(), &Message{Topic: "cats"}, []string{"a"}); err != ErrInvalidMulticastTemplate {
+			t.Fatalf("expected: %v got: %v", ErrInvalidMulticastTemplate, err)
+		}
+	})
+
+	t.Run("dry run without client", func(t *testing.T) {
+		next := &testSender{}
+		s := Decorate(next, DryRun())
+
+		if _, err := s.SendContext(context.Background(), &SendRequest{Message: &Message{Topic: "cats"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := s.SendMulticast(context.Background(), &Message{}, []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.SuccessCount != 2 || resp.Responses[1].Token != "b" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		if _, err := s.SendAll(context.Background(), []*SendRequest{{Message: &Message{Token: "c"}}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if next.multicasts != 0 {
+			t.Fatalf("expected: %v got: %v", 0, next.multicasts)
+		}
+		if len(next.reqs) != 4 {
+			t.Fatalf("expected: %v got: %v", 4, len(next.reqs))
+		}
+		for _, req := range next.reqs {
+			if !req.ValidateOnly {
+				t.Fatalf("expected validate_only to be set: %+v", req.Message)
+			}
+		}
+	})
+
+	t.Run("logging and metrics", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "batchAdd") {
+				w.Write([]byte(`{"results": [{}, {"error": "NOT_FOUND"}]}`))
+				return
+			}
+			w.Write([]byte(`{"name": "projects/test/messages/1"}`))
+		})
+		defer srv.Close()
+
+		var buf bytes.Buffer
+		recorder := &testRecorder{}
+		s := Decorate(c, Logging(log.New(&buf, "", 0)), Metrics(recorder))
+
+		if _, err := s.SendContext(context.Background(), &SendRequest{Message: &Message{Topic: "cats"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := s.SubscribeToTopic(context.Background(), []string{"a", "b"}, "cats"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err := s.SendContext(context.Background(), nil)
+		if err != ErrInvalidMessage {
+			t.Fatalf("expected: %v got: %v", ErrInvalidMessage, err)
+		}
+
+		expected := []recordedCall{
+			{"SendContext", 1, 0, nil},
+			{"SubscribeToTopic", 1, 1, nil},
+			{"SendContext", 0, 1, ErrInvalidMessage},
+		}
+		if len(recorder.calls) != len(expected) {
+			t.Fatalf("expected: %v got: %v", expected, recorder.calls)
+		}
+		for i := range expected {
+			if recorder.calls[i] != expected[i] {
+				t.Fatalf("expected: %v got: %v", expected[i], recorder.calls[i])
+			}
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected: %v got: %v", 3, lines)
+		}
+		if !strings.HasPrefix(lines[1], "fcm: SubscribeToTopic succeeded for 1, failed for 1") {
+			t.Fatalf("unexpected log line: %v", lines[1])
+		}
+		if !strings.HasPrefix(lines[2], "fcm: SendContext failed") {
+			t.Fatalf("unexpected log line: %v", lines[2])
+		}
+	})
+}
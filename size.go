@@ -0,0 +1,259 @@
+package fcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// maximum size of the data payload and of the android and webpush payloads
+	maxPayloadSize = 4096
+
+	// maximum size of an APNs payload, and of a VoIP one
+	maxApnsPayloadSize     = 4096
+	maxApnsVoIPPayloadSize = 5120
+)
+
+// ErrPayloadTooLarge occurs if a payload of the message exceeds its size limit.
+var ErrPayloadTooLarge = errors.New("payload is too large")
+
+// PayloadSize holds the estimated sizes, in bytes, of the payloads a message
+// is delivered as. Sizes are those of the JSON encodings and exclude the keys
+// FCM adds itself, such as the message id.
+type PayloadSize struct {
+	// Size of Message.Data.
+	Data int
+
+	// Size of the payload delivered to Android: the data, or
+	// AndroidConfig.Data if set, and the notification merged with
+	// AndroidConfig.Notification.
+	Android int
+
+	// Size of the APNs payload after FCM merges the notification into the
+	// aps alert and the data into the top level of the payload.
+	Apns int
+
+	// Size of the payload delivered to web apps: the data, or
+	// WebpushConfig.Data if set, and the notification merged with
+	// WebpushConfig.Notification.
+	Webpush int
+}
+
+// EstimateSize estimates the size of the payload of the message for each platform.
+func (msg *Message) EstimateSize() (PayloadSize, error) {
+	var size PayloadSize
+	if msg == nil {
+		return size, ErrInvalidMessage
+	}
+
+	var err error
+	if size.Data, err = jsonSize(msg.Data); err != nil {
+		return size, err
+	}
+
+	if size.Android, err = msg.androidPayloadSize(); err != nil {
+		return size, err
+	}
+
+	if size.Apns, err = msg.apnsPayloadSize(); err != nil {
+		return size, err
+	}
+
+	if size.Webpush, err = msg.webpushPayloadSize(); err != nil {
+		return size, err
+	}
+
+	return size, nil
+}
+
+// validateSize checks the estimated payload sizes against the limits. The
+// Android, APNs and webpush limits are only enforced if the message has an
+// Android, APNs or webpush config.
+func (msg *Message) validateSize(v *validator) {
+	size, err := msg.EstimateSize()
+	if err != nil {
+		// a payload that fails to encode is most likely due to an issue
+		// already found, e.g. an invalid light settings color
+		if len(v.issues) == 0 {
+			v.add("message", err)
+		}
+		return
+	}
+
+	if size.Data > maxPayloadSize {
+		v.add("message.data", payloadTooLarge("data", size.Data, maxPayloadSize))
+	}
+
+	if msg.Android != nil && size.Android > maxPayloadSize {
+		v.add("message.android", payloadTooLarge("android", size.Android, maxPayloadSize))
+	}
+
+	if msg.Apns != nil {
+		limit := maxApnsPayloadSize
+		if msg.Apns.Headers != nil && msg.Apns.Headers.PushType == PushTypeVoIP {
+			limit = maxApnsVoIPPayloadSize
+		}
+
+		if size.Apns > limit {
+			v.add("message.apns.payload", payloadTooLarge("apns", size.Apns, limit))
+		}
+	}
+
+	if msg.Webpush != nil && size.Webpush > maxPayloadSize {
+		v.add("message.webpush", payloadTooLarge("webpush", size.Webpush, maxPayloadSize))
+	}
+}
+
+func payloadTooLarge(platform string, size, limit int) error {
+	return fmt.Errorf("%w: %s payload is %d bytes, the limit is %d bytes", ErrPayloadTooLarge, platform, size, limit)
+}
+
+func (msg *Message) androidPayloadSize() (int, error) {
+	payload := make(map[string]interface{})
+
+	data := msg.Data
+	var notification interface{}
+	if msg.Android != nil {
+		if msg.Android.Data != nil {
+			data = msg.Android.Data
+		}
+		notification = msg.Android.Notification
+	}
+
+	if len(data) > 0 {
+		payload["data"] = data
+	}
+
+	merged, err := mergeJSONObjects(msg.Notification, notification)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(merged) > 0 {
+		payload["notification"] = merged
+	}
+
+	return jsonSize(payload)
+}
+
+func (msg *Message) apnsPayloadSize() (int, error) {
+	var payload interface{}
+	if msg.Apns != nil {
+		payload = msg.Apns.Payload
+		if msg.Apns.TypedPayload != nil {
+			payload = msg.Apns.TypedPayload
+		}
+	}
+
+	merged, err := mergeJSONObjects(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	for k, v := range msg.Data {
+		if _, ok := merged[k]; !ok {
+			merged[k] = v
+		}
+	}
+
+	if msg.Notification != nil && (msg.Notification.Title != "" || msg.Notification.Body != "") {
+		aps, _ := merged["aps"].(map[string]interface{})
+		if aps == nil {
+			aps = make(map[string]interface{})
+			merged["aps"] = aps
+		}
+
+		alert, _ := aps["alert"].(map[string]interface{})
+		if alert == nil {
+			alert = make(map[string]interface{})
+			if body, ok := aps["alert"].(string); ok {
+				alert["body"] = body
+			}
+			aps["alert"] = alert
+		}
+
+		if _, ok := alert["title"]; !ok && msg.Notification.Title != "" {
+			alert["title"] = msg.Notification.Title
+		}
+
+		if _, ok := alert["body"]; !ok && msg.Notification.Body != "" {
+			alert["body"] = msg.Notification.Body
+		}
+	}
+
+	if len(merged) == 0 {
+		return 0, nil
+	}
+
+	return jsonSize(merged)
+}
+
+func (msg *Message) webpushPayloadSize() (int, error) {
+	payload := make(map[string]interface{})
+
+	data := msg.Data
+	var notification interface{}
+	if msg.Webpush != nil {
+		if msg.Webpush.Data != nil {
+			data = msg.Webpush.Data
+		}
+		notification = msg.Webpush.Notification
+	}
+
+	if len(data) > 0 {
+		payload["data"] = data
+	}
+
+	merged, err := mergeJSONObjects(msg.Notification, notification)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(merged) > 0 {
+		payload["notification"] = merged
+	}
+
+	return jsonSize(payload)
+}
+
+// mergeJSONObjects merges the JSON encodings of objects into one map, later
+// objects overriding the top level keys of earlier ones. Nil objects are skipped.
+func mergeJSONObjects(objects ...interface{}) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	for _, o := range objects {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+
+	return merged, nil
+}
+
+// jsonSize returns the size of the JSON encoding of v, or 0 if v is empty.
+func jsonSize(v interface{}) (int, error) {
+	if m, ok := v.(map[string]string); ok && len(m) == 0 {
+		return 0, nil
+	}
+
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 0 {
+		return 0, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
@@ -0,0 +1,112 @@
+package fcm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	msg := &Message{
+		Topic:        "test",
+		Data:         map[string]string{"k": "v"},
+		Notification: &Notification{Title: "t", Body: "b"},
+		Android: &AndroidConfig{
+			Notification: &AndroidNotification{Title: "android"},
+		},
+		Apns: &ApnsConfig{
+			TypedPayload: &ApnsPayload{
+				Aps:    &ApsDictionary{Alert: &ApnsAlert{Title: "apns"}},
+				Custom: map[string]interface{}{"c": 1},
+			},
+		},
+		Webpush: &WebpushConfig{Data: map[string]string{"w": "x"}},
+	}
+
+	size, err := msg.EstimateSize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := PayloadSize{
+		Data:    len(`{"k":"v"}`),
+		Android: len(`{"data":{"k":"v"},"notification":{"body":"b","title":"android"}}`),
+		Apns:    len(`{"aps":{"alert":{"body":"b","title":"apns"}},"c":1,"k":"v"}`),
+		Webpush: len(`{"data":{"w":"x"},"notification":{"body":"b","title":"t"}}`),
+	}
+	if size != expected {
+		t.Fatalf("expected: %+v got: %+v", expected, size)
+	}
+
+	t.Run("empty message", func(t *testing.T) {
+		size, err := (&Message{Topic: "test"}).EstimateSize()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if size != (PayloadSize{}) {
+			t.Fatalf("expected: %+v got: %+v", PayloadSize{}, size)
+		}
+	})
+}
+
+func TestValidateSize(t *testing.T) {
+	big := strings.Repeat("x", 4000)
+
+	tests := []struct {
+		name  string
+		msg   *Message
+		paths []string
+		err   error
+	}{
+		{"data under limit", &Message{Topic: "test", Data: map[string]string{"k": big}}, nil, nil},
+		{"data over limit", &Message{Topic: "test", Data: map[string]string{"k": big, "l": big}}, []string{"message.data"}, ErrPayloadTooLarge},
+		{"data and android over limit", &Message{
+			Topic:   "test",
+			Data:    map[string]string{"k": big, "l": big},
+			Android: &AndroidConfig{Priority: "high"},
+		}, []string{"message.data", "message.android"}, ErrPayloadTooLarge},
+		{"android data over limit", &Message{
+			Topic:   "test",
+			Data:    map[string]string{"k": "v"},
+			Android: &AndroidConfig{Data: map[string]string{"k": big, "l": big}},
+		}, []string{"message.android"}, ErrPayloadTooLarge},
+		{"apns over limit after merge", &Message{
+			Topic: "test",
+			Data:  map[string]string{"k": big},
+			Apns:  &ApnsConfig{TypedPayload: &ApnsPayload{Custom: map[string]interface{}{"c": big[:200]}}},
+		}, []string{"message.apns.payload"}, ErrPayloadTooLarge},
+		{"voip under limit", &Message{
+			Topic: "test",
+			Data:  map[string]string{"k": big},
+			Apns: &ApnsConfig{
+				Headers:      &ApnsHeaders{PushType: PushTypeVoIP},
+				TypedPayload: &ApnsPayload{Custom: map[string]interface{}{"c": big[:200]}},
+			},
+		}, nil, nil},
+		{"webpush over limit", &Message{
+			Topic:   "test",
+			Webpush: &WebpushConfig{Notification: &WebpushNotification{Body: big + big}},
+		}, []string{"message.webpush"}, ErrPayloadTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+
+			var paths []string
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				for _, issue := range verr.Issues {
+					paths = append(paths, issue.Path)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Fatalf("expected: %v got: %v", tt.paths, paths)
+			}
+		})
+	}
+}
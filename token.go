@@ -2,43 +2,107 @@ package fcm
 
 import (
 	"context"
-	"io/ioutil"
+	"net/http"
 
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 const firebaseScope = "https://www.googleapis.com/auth/firebase.messaging"
 
-type tokenProvider struct {
-	tokenSource oauth2.TokenSource
-}
+// tokenSourceFunc returns a token source whose requests are made with ctx.
+type tokenSourceFunc func(ctx context.Context) (oauth2.TokenSource, error)
 
-func newTokenProvider(credentialsLocation string) (*tokenProvider, error) {
-	jsonKey, err := ioutil.ReadFile(credentialsLocation)
-	if err != nil {
-		return nil, errors.Wrapf(err, "fcm: failed to read credentials file at: '%s'", credentialsLocation)
+// staticTokenSourceFunc returns a tokenSourceFunc that always returns ts,
+// for token sources that have no notion of a context.
+func staticTokenSourceFunc(ts oauth2.TokenSource) tokenSourceFunc {
+	return func(ctx context.Context) (oauth2.TokenSource, error) {
+		return ts, nil
 	}
+}
 
-	cfg, err := google.JWTConfigFromJSON(jsonKey, firebaseScope)
-	if err != nil {
-		return nil, errors.Wrapf(err, "fcm: failed to get JWT config for the firebase.messaging scope")
-	}
+type tokenProvider struct {
+	newTokenSource tokenSourceFunc
 
-	ts := cfg.TokenSource(context.Background())
+	// sem serializes the fetches and guards cached
+	sem    chan struct{}
+	cached *oauth2.Token
+}
+
+func newTokenProvider(newTokenSource tokenSourceFunc) *tokenProvider {
 	return &tokenProvider{
-		tokenSource: ts,
-	}, nil
+		newTokenSource: newTokenSource,
+		sem:            make(chan struct{}, 1),
+	}
 }
 
 // token is safe for use from multiple go routines. It will request a token if
-// one does not exist or is expired.
-func (src *tokenProvider) token() (string, error) {
-	token, err := src.tokenSource.Token()
+// one does not exist or is expired. The token source is created with ctx, so
+// that cancelling ctx aborts the fetch. Token sources that ignore ctx are
+// fetched in their own goroutine, and token returns ctx.Err() as soon as ctx
+// is done.
+func (src *tokenProvider) token(ctx context.Context) (string, error) {
+	select {
+	case src.sem <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-src.sem }()
+
+	if src.cached.Valid() {
+		return src.cached.AccessToken, nil
+	}
+
+	ts, err := src.newTokenSource(ctx)
 	if err != nil {
 		return "", errors.Wrapf(err, "fcm: failed to generate Bearer token")
 	}
 
-	return token.AccessToken, nil
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		token, err := ts.Token()
+		ch <- result{token, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		if r.err != nil {
+			return "", errors.Wrapf(r.err, "fcm: failed to generate Bearer token")
+		}
+		src.cached = r.token
+		return r.token.AccessToken, nil
+	}
+}
+
+// withHTTPClient returns a copy of ctx holding, as oauth2.HTTPClient, a copy
+// of client whose requests are bound to ctx. Token sources that don't pass a
+// context to their requests, e.g. the JWT flow of service accounts, are then
+// aborted by cancelling ctx too.
+func withHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	bound := *client
+	bound.Transport = &contextTransport{ctx: ctx, base: client.Transport}
+	return context.WithValue(ctx, oauth2.HTTPClient, &bound)
+}
+
+// contextTransport sends requests with ctx.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(req.WithContext(t.ctx))
 }
@@ -0,0 +1,209 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	topicPrefix = "/topics/"
+
+	// maximum number of registration tokens per batchAdd or batchRemove request
+	maxTopicManagementTokens = 1000
+)
+
+// ErrInvalidTopic occurs if a topic is empty or does not match [a-zA-Z0-9-_.~%]+.
+var ErrInvalidTopic = errors.New("topic is invalid")
+
+var topicNamePattern = regexp.MustCompile(`^[a-zA-Z0-9-_.~%]+$`)
+
+// TopicManagementResponse holds the result of subscribing registration
+// tokens to, or unsubscribing them from, a topic.
+type TopicManagementResponse struct {
+	// Number of tokens that were subscribed or unsubscribed successfully.
+	SuccessCount int
+
+	// Number of tokens that could not be subscribed or unsubscribed.
+	FailureCount int
+
+	// Errors of the individual tokens that failed.
+	Errors []*TopicError
+}
+
+// TopicError describes why a registration token could not be subscribed to,
+// or unsubscribed from, a topic.
+type TopicError struct {
+	// Index of the token in the list passed to SubscribeToTopic or UnsubscribeFromTopic.
+	Index int
+
+	// The registration token that failed.
+	Token string
+
+	// The reason reported by the Instance ID service, e.g. "NOT_FOUND",
+	// "INVALID_ARGUMENT" or "INTERNAL", or the message of Err.
+	Reason string
+
+	// Error of the request, if the chunk of tokens the token belongs to could not be sent.
+	Err error
+}
+
+func (e *TopicError) Error() string {
+	return fmt.Sprintf("fcm: token at index %d: %s", e.Index, e.Reason)
+}
+
+// Unwrap returns the error of the request the token failed with, if any.
+func (e *TopicError) Unwrap() error {
+	return e.Err
+}
+
+type topicManagementRequest struct {
+	To                 string   `json:"to"`
+	RegistrationTokens []string `json:"registration_tokens"`
+}
+
+type topicManagementResult struct {
+	Results []struct {
+		Error string `json:"error,omitempty"`
+	} `json:"results"`
+}
+
+// SubscribeToTopic subscribes the registration tokens to topic. The topic
+// may be given with or without the "/topics/" prefix. Tokens are sent to the
+// Instance ID service in chunks of 1000.
+//
+// If a chunk cannot be sent, the response is returned along with the error:
+// it holds the results of the earlier chunks, and the tokens of the failed
+// and later chunks are reported as failed with that error.
+func (c *Client) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	return c.manageTopic(ctx, "batchAdd", tokens, topic)
+}
+
+// UnsubscribeFromTopic unsubscribes the registration tokens from topic. The
+// topic may be given with or without the "/topics/" prefix. Tokens are sent
+// to the Instance ID service in chunks of 1000. Chunk failures are reported
+// as by SubscribeToTopic.
+func (c *Client) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicManagementResponse, error) {
+	return c.manageTopic(ctx, "batchRemove", tokens, topic)
+}
+
+func (c *Client) manageTopic(ctx context.Context, op string, tokens []string, topic string) (*TopicManagementResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	topic, err := validateTopicManagement(tokens, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/iid/v1:%s", c.iidEndpoint, op)
+	response := &TopicManagementResponse{}
+	for start := 0; start < len(tokens); start += maxTopicManagementTokens {
+		end := start + maxTopicManagementTokens
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		data, err := json.Marshal(&topicManagementRequest{
+			To:                 topicPrefix + topic,
+			RegistrationTokens: tokens[start:end],
+		})
+		if err != nil {
+			return response.failRemaining(tokens, start, err)
+		}
+
+		result := new(topicManagementResult)
+		err = c.do(ctx, func() (*http.Request, error) {
+			return newIIDRequest(http.MethodPost, url, data)
+		}, result)
+		if err != nil {
+			return response.failRemaining(tokens, start, err)
+		}
+
+		if len(result.Results) != end-start {
+			err := fmt.Errorf("fcm: expected %d topic management results, got %d", end-start, len(result.Results))
+			return response.failRemaining(tokens, start, err)
+		}
+
+		for i, r := range result.Results {
+			if r.Error == "" {
+				response.SuccessCount++
+				continue
+			}
+
+			response.FailureCount++
+			response.Errors = append(response.Errors, &TopicError{
+				Index:  start + i,
+				Token:  tokens[start+i],
+				Reason: r.Error,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+// failRemaining reports the tokens from index start on as failed with err,
+// and returns the response along with err.
+func (r *TopicManagementResponse) failRemaining(tokens []string, start int, err error) (*TopicManagementResponse, error) {
+	for i := start; i < len(tokens); i++ {
+		r.FailureCount++
+		r.Errors = append(r.Errors, &TopicError{
+			Index:  i,
+			Token:  tokens[i],
+			Reason: err.Error(),
+			Err:    err,
+		})
+	}
+
+	return r, err
+}
+
+// validateTopicManagement validates the arguments of a topic management
+// request and returns the topic without the "/topics/" prefix.
+func validateTopicManagement(tokens []string, topic string) (string, error) {
+	topic = NormalizeTopic(topic)
+	if topic == "" {
+		return "", ErrInvalidTopic
+	}
+
+	if err := validateTopicName(topic); err != nil {
+		return "", err
+	}
+
+	if len(tokens) == 0 {
+		return "", ErrNoTokens
+	}
+
+	for i, token := range tokens {
+		if token == "" {
+			return "", fmt.Errorf("registration token at index %d is empty", i)
+		}
+	}
+
+	return topic, nil
+}
+
+// NormalizeTopic strips the "/topics/" prefix of legacy topic names, e.g.
+// "/topics/news" becomes "news".
+func NormalizeTopic(topic string) string {
+	return strings.TrimPrefix(topic, topicPrefix)
+}
+
+// validateTopicName checks that topic is a topic name without the "/topics/" prefix.
+func validateTopicName(topic string) error {
+	if strings.HasPrefix(topic, topicPrefix) {
+		return fmt.Errorf("%w: '%s' must not start with '%s', use NormalizeTopic or WithTopicNormalization", ErrInvalidTopic, topic, topicPrefix)
+	}
+
+	if !topicNamePattern.MatchString(topic) {
+		return fmt.Errorf("%w: '%s' must match [a-zA-Z0-9-_.~%%]+", ErrInvalidTopic, topic)
+	}
+
+	return nil
+}
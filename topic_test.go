@@ -0,0 +1,136 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestManageTopic(t *testing.T) {
+	t.Run("chunks tokens", func(t *testing.T) {
+		var chunks []int
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/iid/v1:batchAdd" {
+				t.Errorf("unexpected path: %v", r.URL.Path)
+			}
+			if r.Header.Get("access_token_auth") != "true" {
+				t.Error("expected access_token_auth header")
+			}
+
+			var req topicManagementRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if req.To != "/topics/cats" {
+				t.Errorf("expected: %v got: %v", "/topics/cats", req.To)
+			}
+			chunks = append(chunks, len(req.RegistrationTokens))
+
+			results := make([]string, len(req.RegistrationTokens))
+			for i, token := range req.RegistrationTokens {
+				results[i] = "{}"
+				if token == "bad" {
+					results[i] = `{"error": "INVALID_ARGUMENT"}`
+				}
+			}
+			w.Write([]byte(`{"results": [` + strings.Join(results, ",") + `]}`))
+		})
+		defer srv.Close()
+
+		tokens := make([]string, 1500)
+		for i := range tokens {
+			tokens[i] = "good"
+		}
+		tokens[3] = "bad"
+		tokens[1203] = "bad"
+
+		resp, err := c.SubscribeToTopic(context.Background(), tokens, "/topics/cats")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(chunks) != 2 || chunks[0] != 1000 || chunks[1] != 500 {
+			t.Fatalf("unexpected chunks: %v", chunks)
+		}
+		if resp.SuccessCount != 1498 || resp.FailureCount != 2 {
+			t.Fatalf("unexpected counts: %d successes, %d failures", resp.SuccessCount, resp.FailureCount)
+		}
+		if resp.Errors[0].Index != 3 || resp.Errors[1].Index != 1203 || resp.Errors[1].Reason != "INVALID_ARGUMENT" {
+			t.Fatalf("unexpected errors: %v", resp.Errors)
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/iid/v1:batchRemove" {
+				t.Errorf("unexpected path: %v", r.URL.Path)
+			}
+			w.Write([]byte(`{"results": [{"error": "NOT_FOUND"}]}`))
+		})
+		defer srv.Close()
+
+		resp, err := c.UnsubscribeFromTopic(context.Background(), []string{"a"}, "cats")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.FailureCount != 1 || resp.Errors[0].Token != "a" || resp.Errors[0].Reason != "NOT_FOUND" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "InvalidTopicName"}`))
+		})
+		defer srv.Close()
+
+		_, err := c.SubscribeToTopic(context.Background(), []string{"a"}, "cats")
+		if !IsInvalidArgument(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeInvalidArgument, err)
+		}
+	})
+
+	t.Run("chunk failure", func(t *testing.T) {
+		var calls int
+		c, srv := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls > 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			var req topicManagementRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			w.Write([]byte(`{"results": [` + strings.TrimSuffix(strings.Repeat("{},", len(req.RegistrationTokens)), ",") + `]}`))
+		})
+		defer srv.Close()
+
+		tokens := make([]string, 1500)
+		for i := range tokens {
+			tokens[i] = "good"
+		}
+
+		resp, err := c.SubscribeToTopic(context.Background(), tokens, "cats")
+		if !IsInternal(err) {
+			t.Fatalf("expected %v, got %v", ErrorCodeInternal, err)
+		}
+		if resp == nil || resp.SuccessCount != 1000 || resp.FailureCount != 500 || len(resp.Errors) != 500 {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		if resp.Errors[0].Index != 1000 || !IsInternal(resp.Errors[0]) {
+			t.Fatalf("unexpected error: %v", resp.Errors[0])
+		}
+	})
+
+	t.Run("invalid topic", func(t *testing.T) {
+		c := &Client{}
+		_, err := c.SubscribeToTopic(context.Background(), []string{"a"}, "/topics/")
+		if err != ErrInvalidTopic {
+			t.Fatalf("expected: %v got: %v", ErrInvalidTopic, err)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package fcm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationError is returned by Message.Validate and holds every problem
+// found in the message. It matches each of the errors of its issues with
+// errors.Is and errors.As, e.g. errors.Is(err, ErrInvalidTimeToLive).
+type ValidationError struct {
+	Issues []*ValidationIssue
+}
+
+// ValidationIssue is a problem with one field of a message.
+type ValidationIssue struct {
+	// JSON path of the field, e.g. "message.android.notification.color".
+	Path string
+
+	// Machine-readable code of the problem, e.g. "INVALID_TTL".
+	Code string
+
+	// Human readable description of the problem.
+	Message string
+
+	// The underlying error, which wraps one of the Err* errors of this package.
+	Err error
+}
+
+func (i *ValidationIssue) Error() string {
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// Unwrap returns the underlying error.
+func (i *ValidationIssue) Unwrap() error {
+	return i.Err
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Issues) == 1 {
+		return e.Issues[0].Error()
+	}
+
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.Error()
+	}
+
+	return fmt.Sprintf("%d validation errors: %s", len(e.Issues), strings.Join(msgs, "; "))
+}
+
+// Is reports whether the error of any issue matches target. It lets errors.Is
+// look into the issues on Go versions before 1.20, which ignore Unwrap.
+func (e *ValidationError) Is(target error) bool {
+	for _, issue := range e.Issues {
+		if errors.Is(issue, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first error of the issues that matches target, as errors.As
+// does. It lets errors.As look into the issues on Go versions before 1.20.
+func (e *ValidationError) As(target interface{}) bool {
+	for _, issue := range e.Issues {
+		if errors.As(issue, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unwrap returns the issues, so that errors.Is and errors.As look into each.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Issues))
+	for i, issue := range e.Issues {
+		errs[i] = issue
+	}
+
+	return errs
+}
+
+// validationCodes maps the errors of this package to the codes of validation
+// issues. Errors are matched in order with errors.Is.
+var validationCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrInvalidMessage, "INVALID_MESSAGE"},
+	{ErrInvalidTopic, "INVALID_TOPIC"},
+	{ErrInvalidToken, "INVALID_TOKEN"},
+	{ErrInvalidTarget, "INVALID_TARGET"},
+	{ErrReservedDataKey, "RESERVED_DATA_KEY"},
+	{ErrPayloadTooLarge, "PAYLOAD_TOO_LARGE"},
+	{ErrInvalidImage, "INVALID_IMAGE"},
+	{ErrInvalidAnalyticsLabel, "INVALID_ANALYTICS_LABEL"},
+	{ErrInvalidTimeToLive, "INVALID_TTL"},
+	{ErrInvalidNotificationPriority, "INVALID_NOTIFICATION_PRIORITY"},
+	{ErrInvalidVisibility, "INVALID_VISIBILITY"},
+	{ErrInvalidVibrateTimings, "INVALID_VIBRATE_TIMINGS"},
+	{ErrInvalidNotificationCount, "INVALID_NOTIFICATION_COUNT"},
+	{ErrInvalidLightSettings, "INVALID_LIGHT_SETTINGS"},
+	{ErrInvalidAndroidPriority, "INVALID_ANDROID_PRIORITY"},
+	{ErrInvalidColor, "INVALID_COLOR"},
+	{ErrInvalidPackageName, "INVALID_PACKAGE_NAME"},
+	{ErrInvalidLocArgs, "INVALID_LOC_ARGS"},
+	{ErrInvalidChannelID, "INVALID_CHANNEL_ID"},
+	{ErrInvalidWebpushTTL, "INVALID_WEBPUSH_TTL"},
+	{ErrInvalidWebpushUrgency, "INVALID_WEBPUSH_URGENCY"},
+	{ErrInvalidWebpushTopic, "INVALID_WEBPUSH_TOPIC"},
+	{ErrInvalidWebpushLink, "INVALID_WEBPUSH_LINK"},
+	{ErrReservedApnsKey, "RESERVED_APNS_KEY"},
+	{ErrInvalidApnsPushType, "INVALID_APNS_PUSH_TYPE"},
+	{ErrInvalidApnsPriority, "INVALID_APNS_PRIORITY"},
+	{ErrInvalidApnsExpiration, "INVALID_APNS_EXPIRATION"},
+	{ErrInvalidApnsCollapseID, "INVALID_APNS_COLLAPSE_ID"},
+	{ErrUnknownApsKey, "UNKNOWN_APS_KEY"},
+}
+
+// validationCode returns the code of a validation issue caused by err.
+func validationCode(err error) string {
+	var condErr *ConditionError
+	if errors.As(err, &condErr) {
+		return "INVALID_CONDITION"
+	}
+
+	for _, c := range validationCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return "INVALID_ARGUMENT"
+}
+
+// validator collects the issues found while validating a message.
+type validator struct {
+	issues []*ValidationIssue
+}
+
+// add records err as an issue with the field at path. A nil err is ignored.
+func (v *validator) add(path string, err error) {
+	if err == nil {
+		return
+	}
+
+	v.issues = append(v.issues, &ValidationIssue{
+		Path:    path,
+		Code:    validationCode(err),
+		Message: err.Error(),
+		Err:     err,
+	})
+}
+
+// err returns a *ValidationError holding the issues, or nil if there are none.
+func (v *validator) err() error {
+	if len(v.issues) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Issues: v.issues}
+}
@@ -0,0 +1,142 @@
+package fcm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationError(t *testing.T) {
+	t.Run("collects every issue", func(t *testing.T) {
+		count := -1
+		msg := &Message{
+			Topic: "/topics/news",
+			Data:  map[string]string{"from": "x"},
+			Android: &AndroidConfig{
+				TTL: "5",
+				Notification: &AndroidNotification{
+					NotificationCount: &count,
+					LightSettings:     &LightSettings{Color: "#rrggbb", LightOnDuration: 1},
+				},
+			},
+			Webpush: &WebpushConfig{
+				Headers:    WebpushHeaders{"urgency": "now"},
+				FcmOptions: &WebpushFcmOptions{Link: "http://example.com"},
+			},
+		}
+
+		err := msg.Validate()
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected *ValidationError, got: %v", err)
+		}
+
+		var paths, codes []string
+		for _, issue := range verr.Issues {
+			paths = append(paths, issue.Path)
+			codes = append(codes, issue.Code)
+		}
+
+		expectedPaths := []string{
+			"message.topic",
+			"message.data.from",
+			"message.android.ttl",
+			"message.android.notification.notification_count",
+			"message.android.notification.light_settings.color",
+			"message.android.notification.light_settings.light_off_duration",
+			"message.webpush.headers.Urgency",
+			"message.webpush.fcm_options.link",
+		}
+		if !reflect.DeepEqual(paths, expectedPaths) {
+			t.Fatalf("expected: %v got: %v", expectedPaths, paths)
+		}
+
+		expectedCodes := []string{
+			"INVALID_TOPIC",
+			"RESERVED_DATA_KEY",
+			"INVALID_TTL",
+			"INVALID_NOTIFICATION_COUNT",
+			"INVALID_LIGHT_SETTINGS",
+			"INVALID_LIGHT_SETTINGS",
+			"INVALID_WEBPUSH_URGENCY",
+			"INVALID_WEBPUSH_LINK",
+		}
+		if !reflect.DeepEqual(codes, expectedCodes) {
+			t.Fatalf("expected: %v got: %v", expectedCodes, codes)
+		}
+
+		for _, sentinel := range []error{ErrInvalidTopic, ErrReservedDataKey, ErrInvalidTimeToLive, ErrInvalidWebpushLink} {
+			if !errors.Is(err, sentinel) {
+				t.Fatalf("expected error to match %v", sentinel)
+			}
+		}
+
+		if errors.Is(err, ErrInvalidTarget) {
+			t.Fatalf("unexpected match of %v", ErrInvalidTarget)
+		}
+
+		if !strings.HasPrefix(err.Error(), "8 validation errors: message.topic: ") {
+			t.Fatalf("unexpected message: %v", err)
+		}
+	})
+
+	t.Run("single issue", func(t *testing.T) {
+		err := (&Message{}).Validate()
+
+		expected := "message: " + ErrInvalidTarget.Error()
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected: %v got: %v", expected, err)
+		}
+	})
+
+	t.Run("nil message", func(t *testing.T) {
+		var msg *Message
+		err := msg.Validate()
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) || len(verr.Issues) != 1 || verr.Issues[0].Path != "message" || verr.Issues[0].Code != "INVALID_MESSAGE" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !errors.Is(err, ErrInvalidMessage) {
+			t.Fatalf("expected error to match %v", ErrInvalidMessage)
+		}
+	})
+
+	t.Run("is and as without unwrap", func(t *testing.T) {
+		// errors.Is and errors.As before Go 1.20 only call these methods
+		verr := &ValidationError{Issues: []*ValidationIssue{
+			{Path: "message.topic", Err: ErrInvalidTopic},
+			{Path: "message.condition", Err: &ConditionError{Pos: 1}},
+		}}
+
+		if !verr.Is(ErrInvalidTopic) || !verr.Is(ErrInvalidTarget) || verr.Is(ErrInvalidToken) {
+			t.Fatalf("unexpected Is: %v", verr)
+		}
+
+		var condErr *ConditionError
+		if !verr.As(&condErr) || condErr.Pos != 1 {
+			t.Fatalf("expected *ConditionError, got: %v", condErr)
+		}
+
+		var issue *ValidationIssue
+		if !verr.As(&issue) || issue.Path != "message.topic" {
+			t.Fatalf("expected first issue, got: %v", issue)
+		}
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		err := (&Message{Condition: "'a' in"}).Validate()
+
+		var condErr *ConditionError
+		if !errors.As(err, &condErr) {
+			t.Fatalf("expected *ConditionError, got: %v", err)
+		}
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Issues[0].Path != "message.condition" || verr.Issues[0].Code != "INVALID_CONDITION" {
+			t.Fatalf("unexpected issue: %v", err)
+		}
+	})
+}
@@ -1,5 +1,31 @@
 package fcm
 
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidWebpushUrgency occurs if the Urgency header is not one of the Urgency values.
+	ErrInvalidWebpushUrgency = errors.New("webpush urgency is invalid")
+
+	// ErrInvalidWebpushTTL occurs if the TTL header is not a non-negative number of seconds.
+	ErrInvalidWebpushTTL = errors.New("webpush ttl is invalid")
+
+	// ErrInvalidWebpushTopic occurs if the Topic header is longer than 32 characters
+	// or uses characters outside of the URL and filename safe base64 alphabet.
+	ErrInvalidWebpushTopic = errors.New("webpush topic is invalid")
+
+	// ErrInvalidWebpushLink occurs if the fcm_options link is not an HTTPS URL.
+	ErrInvalidWebpushLink = errors.New("webpush link must be an https url")
+)
+
+var webpushTopicPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
 // WebpushConfig represents the Webpush protocol outlined in https://tools.ietf.org/html/rfc8030
 // https://firebase.google.com/docs/reference/fcm/rest/v1/projects.messages#WebpushConfig
 type WebpushConfig struct {
@@ -8,7 +34,7 @@ type WebpushConfig struct {
 
 	// An object containing a list of "key": value pairs.
 	// Example: { "name": "wrench", "mass": "1.3kg", "count": "3" }.
-	Headers map[string]string `json:"headers,omitempty"`
+	Headers WebpushHeaders `json:"headers,omitempty"`
 
 	// Arbitrary key/value payload. If present, it will override
 	// google.firebase.fcm.v1.Message.data.
@@ -19,6 +45,146 @@ type WebpushConfig struct {
 
 	// A web notification to send.
 	Notification *WebpushNotification `json:"notification,omitempty"`
+
+	// Options for features provided by the FCM SDK for Web.
+	FcmOptions *WebpushFcmOptions `json:"fcm_options,omitempty"`
+}
+
+// validate checks the data, the headers and the link of the config at path.
+func (w *WebpushConfig) validate(v *validator, path string) {
+	validateData(v, path+".data", w.Data)
+
+	if ttl, ok := w.Headers.get(webpushTTLHeader); ok {
+		if secs, err := strconv.ParseInt(ttl, 10, 64); err != nil || secs < 0 {
+			v.add(path+".headers.TTL", ErrInvalidWebpushTTL)
+		}
+	}
+
+	if urgency, ok := w.Headers.get(webpushUrgencyHeader); ok {
+		switch Urgency(urgency) {
+		case UrgencyVeryLow, UrgencyLow, UrgencyNormal, UrgencyHigh:
+		default:
+			v.add(path+".headers.Urgency", ErrInvalidWebpushUrgency)
+		}
+	}
+
+	if topic, ok := w.Headers.get(webpushTopicHeader); ok && !webpushTopicPattern.MatchString(topic) {
+		v.add(path+".headers.Topic", ErrInvalidWebpushTopic)
+	}
+
+	if w.FcmOptions != nil {
+		if w.FcmOptions.Link != "" && !isHTTPSURL(w.FcmOptions.Link) {
+			v.add(path+".fcm_options.link", ErrInvalidWebpushLink)
+		}
+
+		if !validAnalyticsLabel(w.FcmOptions.AnalyticsLabel) {
+			v.add(path+".fcm_options.analytics_label", ErrInvalidAnalyticsLabel)
+		}
+	}
+}
+
+const (
+	webpushTTLHeader     = "TTL"
+	webpushUrgencyHeader = "Urgency"
+	webpushTopicHeader   = "Topic"
+)
+
+// WebpushHeaders are the HTTP headers of a webpush message. Besides
+// arbitrary headers, it has typed accessors for the TTL, Urgency and Topic
+// headers of RFC 8030.
+type WebpushHeaders map[string]string
+
+// SetTTL sets how long the push service retains the message if the user
+// agent is not reachable. The TTL is sent in whole seconds.
+func (h *WebpushHeaders) SetTTL(ttl time.Duration) {
+	h.set(webpushTTLHeader, strconv.FormatInt(int64(ttl/time.Second), 10))
+}
+
+// TTL returns the TTL header and whether it is set and valid.
+func (h WebpushHeaders) TTL() (time.Duration, bool) {
+	ttl, _ := h.get(webpushTTLHeader)
+	secs, err := strconv.ParseInt(ttl, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+
+	return time.Duration(secs) * time.Second, true
+}
+
+// SetUrgency sets the urgency of the message, which user agents use to save battery.
+func (h *WebpushHeaders) SetUrgency(urgency Urgency) {
+	h.set(webpushUrgencyHeader, string(urgency))
+}
+
+// Urgency returns the Urgency header, or "" if it is not set.
+func (h WebpushHeaders) Urgency() Urgency {
+	urgency, _ := h.get(webpushUrgencyHeader)
+	return Urgency(urgency)
+}
+
+// SetTopic sets the topic of the message. A message replaces pending
+// messages with the same topic. The topic has at most 32 characters from the
+// URL and filename safe base64 alphabet.
+func (h *WebpushHeaders) SetTopic(topic string) {
+	h.set(webpushTopicHeader, topic)
+}
+
+// Topic returns the Topic header, or "" if it is not set.
+func (h WebpushHeaders) Topic() string {
+	topic, _ := h.get(webpushTopicHeader)
+	return topic
+}
+
+// get returns the value of a header, matching its name case-insensitively.
+func (h WebpushHeaders) get(key string) (string, bool) {
+	for k, v := range h {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// set sets a header, replacing any header of the same name in another case.
+func (h *WebpushHeaders) set(key, value string) {
+	if *h == nil {
+		*h = make(WebpushHeaders)
+	}
+
+	for k := range *h {
+		if strings.EqualFold(k, key) {
+			delete(*h, k)
+		}
+	}
+	(*h)[key] = value
+}
+
+// Urgency is the urgency of a webpush message as defined by RFC 8030.
+type Urgency string
+
+const (
+	// UrgencyVeryLow is for messages delivered only on power and wifi, e.g. advertisements.
+	UrgencyVeryLow Urgency = "very-low"
+
+	// UrgencyLow is for messages delivered on either power or wifi, e.g. topic updates.
+	UrgencyLow Urgency = "low"
+
+	// UrgencyNormal is for messages delivered on neither power nor wifi, e.g. a chat message.
+	UrgencyNormal Urgency = "normal"
+
+	// UrgencyHigh is for messages delivered even on low battery, e.g. an incoming phone call.
+	UrgencyHigh Urgency = "high"
+)
+
+// WebpushFcmOptions holds options for features provided by the FCM SDK for Web.
+type WebpushFcmOptions struct {
+	// The link to open when the user clicks on the notification. For all URL
+	// values, HTTPS is required.
+	Link string `json:"link,omitempty"`
+
+	// Label associated with the message's analytics data.
+	AnalyticsLabel string `json:"analytics_label,omitempty"`
 }
 
 // WebpushNotification represents a web notification to send via webpush protocol.
@@ -34,4 +200,103 @@ type WebpushNotification struct {
 
 	// The URL to use for the notification's icon.
 	Icon string `json:"icon,omitempty"`
+
+	// Actions the user can take on the notification.
+	Actions []*WebpushNotificationAction `json:"actions,omitempty"`
+
+	// The URL of the image that represents the notification when there is
+	// not enough space to display the notification itself.
+	Badge string `json:"badge,omitempty"`
+
+	// The URL of an image to display in the notification.
+	Image string `json:"image,omitempty"`
+
+	// The direction in which to display the notification: "auto", "ltr" or "rtl".
+	Dir string `json:"dir,omitempty"`
+
+	// The language of the notification, as a BCP 47 language tag.
+	Lang string `json:"lang,omitempty"`
+
+	// An identifier used to replace an existing notification with the same tag.
+	Tag string `json:"tag,omitempty"`
+
+	// Whether the user is notified again when the notification replaces an
+	// older one with the same tag.
+	Renotify bool `json:"renotify,omitempty"`
+
+	// Whether the notification remains active until the user clicks or
+	// dismisses it, rather than closing automatically.
+	RequireInteraction bool `json:"requireInteraction,omitempty"`
+
+	// Whether the notification is silent: no sound or vibration.
+	Silent bool `json:"silent,omitempty"`
+
+	// The time at which the notification was created. Encoded in
+	// milliseconds since the UNIX epoch.
+	Timestamp time.Time `json:"-"`
+
+	// A vibration pattern that alternates between vibration and pause.
+	// Encoded in milliseconds.
+	Vibrate []time.Duration `json:"-"`
+
+	// Arbitrary data associated with the notification.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// MarshalJSON encodes Timestamp and Vibrate in milliseconds.
+func (n WebpushNotification) MarshalJSON() ([]byte, error) {
+	type alias WebpushNotification
+	s := struct {
+		alias
+		Timestamp int64   `json:"timestamp,omitempty"`
+		Vibrate   []int64 `json:"vibrate,omitempty"`
+	}{alias: alias(n)}
+
+	if !n.Timestamp.IsZero() {
+		s.Timestamp = n.Timestamp.UnixNano() / int64(time.Millisecond)
+	}
+
+	for _, d := range n.Vibrate {
+		s.Vibrate = append(s.Vibrate, int64(d/time.Millisecond))
+	}
+
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON decodes the encoding of MarshalJSON.
+func (n *WebpushNotification) UnmarshalJSON(b []byte) error {
+	type alias WebpushNotification
+	s := struct {
+		*alias
+		Timestamp int64   `json:"timestamp,omitempty"`
+		Vibrate   []int64 `json:"vibrate,omitempty"`
+	}{alias: (*alias)(n)}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	n.Timestamp = time.Time{}
+	if s.Timestamp != 0 {
+		n.Timestamp = time.Unix(0, s.Timestamp*int64(time.Millisecond))
+	}
+
+	n.Vibrate = nil
+	for _, ms := range s.Vibrate {
+		n.Vibrate = append(n.Vibrate, time.Duration(ms)*time.Millisecond)
+	}
+
+	return nil
+}
+
+// WebpushNotificationAction is an action the user can take on a web notification.
+type WebpushNotificationAction struct {
+	// The identifier of the action, passed to the service worker when the user clicks it.
+	Action string `json:"action,omitempty"`
+
+	// The title of the action shown to the user.
+	Title string `json:"title,omitempty"`
+
+	// The URL of the icon of the action.
+	Icon string `json:"icon,omitempty"`
 }
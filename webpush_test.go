@@ -0,0 +1,88 @@
+package fcm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWebpushHeaders(t *testing.T) {
+	var h WebpushHeaders
+	h.SetTTL(90 * time.Second)
+	h.SetUrgency(UrgencyHigh)
+	h.SetTopic("scores")
+
+	expected := WebpushHeaders{"TTL": "90", "Urgency": "high", "Topic": "scores"}
+	if !reflect.DeepEqual(h, expected) {
+		t.Fatalf("expected: %v got: %v", expected, h)
+	}
+
+	if ttl, ok := h.TTL(); !ok || ttl != 90*time.Second {
+		t.Fatalf("expected: %v got: %v", 90*time.Second, ttl)
+	}
+
+	h = WebpushHeaders{"urgency": "low"}
+	h.SetUrgency(UrgencyNormal)
+	if len(h) != 1 || h.Urgency() != UrgencyNormal {
+		t.Fatalf("expected: %v got: %v", UrgencyNormal, h)
+	}
+}
+
+func TestWebpushNotificationJSON(t *testing.T) {
+	n := &WebpushNotification{
+		Title:              "title",
+		RequireInteraction: true,
+		Timestamp:          time.Unix(1600000000, 500*int64(time.Millisecond)),
+		Vibrate:            []time.Duration{200 * time.Millisecond, 100 * time.Millisecond},
+		Actions:            []*WebpushNotificationAction{{Action: "open", Title: "Open"}},
+	}
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"title":"title","actions":[{"action":"open","title":"Open"}],"requireInteraction":true,"timestamp":1600000000500,"vibrate":[200,100]}`
+	if string(b) != expected {
+		t.Fatalf("expected: %v got: %v", expected, string(b))
+	}
+
+	var decoded WebpushNotification
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.Timestamp.Equal(n.Timestamp) || !reflect.DeepEqual(decoded.Vibrate, n.Vibrate) {
+		t.Fatalf("expected: %+v got: %+v", n, decoded)
+	}
+}
+
+func TestWebpushValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		webpush *WebpushConfig
+		err     error
+	}{
+		{"valid", &WebpushConfig{
+			Headers:    WebpushHeaders{"TTL": "60", "Urgency": "very-low", "Topic": "a_b-c"},
+			FcmOptions: &WebpushFcmOptions{Link: "https://example.com/inbox"},
+		}, nil},
+		{"invalid urgency", &WebpushConfig{Headers: WebpushHeaders{"urgency": "urgent"}}, ErrInvalidWebpushUrgency},
+		{"negative ttl", &WebpushConfig{Headers: WebpushHeaders{"TTL": "-1"}}, ErrInvalidWebpushTTL},
+		{"invalid topic", &WebpushConfig{Headers: WebpushHeaders{"Topic": "a topic"}}, ErrInvalidWebpushTopic},
+		{"http link", &WebpushConfig{FcmOptions: &WebpushFcmOptions{Link: "http://example.com"}}, ErrInvalidWebpushLink},
+		{"relative link", &WebpushConfig{FcmOptions: &WebpushFcmOptions{Link: "/inbox"}}, ErrInvalidWebpushLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &Message{Topic: "test", Webpush: tt.webpush}
+
+			err := msg.Validate()
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected: %v got: %v", tt.err, err)
+			}
+		})
+	}
+}